@@ -0,0 +1,195 @@
+package fperf
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Assertion is a condition the result of a benchmark must meet, like p99<50ms,
+//errors<0.1% or qps>2000
+type Assertion struct {
+	Metric  string  //qps, errors, timeouts, mean, stddev, min, max or a percentile like p99.9
+	Op      string  //<, <=, > or >=
+	Value   float64 //nanoseconds for the latencies
+	Percent bool    //errors or timeouts in percent of the requests instead of a number
+}
+
+//assertionOps are ordered to match <= before <
+var assertionOps = []string{"<=", ">=", "<", ">"}
+
+//ParseAssertion parses an assertion in the form of metric, operator and value
+func ParseAssertion(s string) (Assertion, error) {
+	var a Assertion
+	i := strings.IndexAny(s, "<>")
+	if i < 0 {
+		return a, fmt.Errorf("invalid assertion %q, should be like p99<50ms", s)
+	}
+	a.Metric = strings.TrimSpace(s[:i])
+	for _, op := range assertionOps {
+		if strings.HasPrefix(s[i:], op) {
+			a.Op = op
+			break
+		}
+	}
+	value := strings.TrimSpace(s[i+len(a.Op):])
+
+	var err error
+	switch {
+	case a.Metric == "qps":
+		a.Value, err = strconv.ParseFloat(value, 64)
+	case a.Metric == "errors" || a.Metric == "timeouts":
+		if strings.HasSuffix(value, "%") {
+			a.Percent = true
+			value = strings.TrimSuffix(value, "%")
+		}
+		a.Value, err = strconv.ParseFloat(value, 64)
+	case a.Metric == "mean" || a.Metric == "stddev" || a.Metric == "min" || a.Metric == "max" || isPercentile(a.Metric):
+		var d time.Duration
+		d, err = time.ParseDuration(value)
+		a.Value = float64(d)
+	default:
+		return a, fmt.Errorf("unknown metric %q in assertion %q", a.Metric, s)
+	}
+	if err != nil {
+		return a, fmt.Errorf("invalid value %q in assertion %q", value, s)
+	}
+	return a, nil
+}
+
+//isPercentile reports whether metric is like p99 or p99.9
+func isPercentile(metric string) bool {
+	if !strings.HasPrefix(metric, "p") {
+		return false
+	}
+	q, err := strconv.ParseFloat(metric[1:], 64)
+	return err == nil && q >= 0 && q <= 100
+}
+
+//Actual returns the value of the metric in res
+func (a Assertion) Actual(res *Result) float64 {
+	h := res.Histogram
+	switch a.Metric {
+	case "qps":
+		return res.QPS()
+	case "errors", "timeouts":
+		n := res.Errors
+		if a.Metric == "timeouts" {
+			n = res.Timeouts
+		}
+		if !a.Percent {
+			return float64(n)
+		}
+		if res.Requests == 0 {
+			return 0
+		}
+		return float64(n) * 100 / float64(res.Requests)
+	case "mean":
+		return h.Mean()
+	case "stddev":
+		return h.StdDev()
+	case "min":
+		return float64(h.Percentile(0))
+	case "max":
+		return float64(h.Percentile(100))
+	}
+	q, _ := strconv.ParseFloat(a.Metric[1:], 64)
+	return float64(h.Percentile(q))
+}
+
+//missing returns why res has no value of the metric, or "" if it has one. The
+//latencies need a successful request and the percentages a request
+func (a Assertion) missing(res *Result) string {
+	switch a.Metric {
+	case "qps":
+		return ""
+	case "errors", "timeouts":
+		if a.Percent && res.Requests == 0 {
+			return "no requests"
+		}
+		return ""
+	}
+	if res.Histogram.Count == 0 {
+		return "no successful requests"
+	}
+	return ""
+}
+
+//Check reports whether res meets the assertion, it fails if res has no value of the metric
+func (a Assertion) Check(res *Result) bool {
+	if a.missing(res) != "" {
+		return false
+	}
+	v := a.Actual(res)
+	switch a.Op {
+	case "<":
+		return v < a.Value
+	case "<=":
+		return v <= a.Value
+	case ">":
+		return v > a.Value
+	}
+	return v >= a.Value
+}
+
+//format formats v in the unit of the metric
+func (a Assertion) format(v float64) string {
+	switch {
+	case a.Metric == "qps":
+		return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+	case (a.Metric == "errors" || a.Metric == "timeouts") && a.Percent:
+		return strconv.FormatFloat(v, 'g', 4, 64) + "%"
+	case a.Metric == "errors" || a.Metric == "timeouts":
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return roundDuration(time.Duration(v)).String()
+}
+
+func (a Assertion) String() string {
+	return a.Metric + a.Op + a.format(a.Value)
+}
+
+//Assertions can be used as a flag.Value and be set multiple times
+type Assertions []Assertion
+
+//Set parses and appends an assertion
+func (as *Assertions) Set(s string) error {
+	a, err := ParseAssertion(s)
+	if err != nil {
+		return err
+	}
+	*as = append(*as, a)
+	return nil
+}
+
+func (as *Assertions) String() string {
+	if as == nil {
+		return ""
+	}
+	names := make([]string, len(*as))
+	for i, a := range *as {
+		names[i] = a.String()
+	}
+	return strings.Join(names, ",")
+}
+
+//Check writes every assertion and its actual value to w, it returns the number of failed ones
+func (as Assertions) Check(w io.Writer, res *Result) int {
+	failed := 0
+	for _, a := range as {
+		status := "PASS"
+		if !a.Check(res) {
+			status = "FAIL"
+			failed++
+		}
+		if reason := a.missing(res); reason != "" {
+			fmt.Fprintf(w, "%s  %s  (%s)\n", status, a, reason)
+			continue
+		}
+		fmt.Fprintf(w, "%s  %s  (%s is %s)\n", status, a, a.Metric, a.format(a.Actual(res)))
+	}
+	return failed
+}
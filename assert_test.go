@@ -0,0 +1,93 @@
+package fperf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAssertion(t *testing.T) {
+	for _, c := range []struct {
+		s      string
+		expect Assertion
+	}{
+		{"p99<50ms", Assertion{Metric: "p99", Op: "<", Value: float64(50 * time.Millisecond)}},
+		{"p99.9 <= 1s", Assertion{Metric: "p99.9", Op: "<=", Value: float64(time.Second)}},
+		{"errors<0.1%", Assertion{Metric: "errors", Op: "<", Value: 0.1, Percent: true}},
+		{"errors<=10", Assertion{Metric: "errors", Op: "<=", Value: 10}},
+		{"timeouts<1%", Assertion{Metric: "timeouts", Op: "<", Value: 1, Percent: true}},
+		{"qps>2000", Assertion{Metric: "qps", Op: ">", Value: 2000}},
+		{"mean>=1.5ms", Assertion{Metric: "mean", Op: ">=", Value: float64(1500 * time.Microsecond)}},
+	} {
+		a, err := ParseAssertion(c.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a != c.expect {
+			t.Fatalf("expect %+v for %q, got %+v", c.expect, c.s, a)
+		}
+	}
+	for _, s := range []string{"p99", "p99=50ms", "latency<1ms", "p101<1ms", "p99<50", "qps>fast", "errors<x%"} {
+		if _, err := ParseAssertion(s); err == nil {
+			t.Fatalf("expect error for %q", s)
+		}
+	}
+}
+
+func TestAssertionsCheck(t *testing.T) {
+	res := testResult(1000, 10*time.Millisecond)
+	res.Requests = 1010
+	res.Errors = 10
+
+	var as Assertions
+	for _, s := range []string{"p99<50ms", "qps>=1000", "errors<0.1%", "errors<=10", "max<10ms"} {
+		if err := as.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+	if failed := as.Check(buf, res); failed != 2 {
+		t.Fatalf("expect 2 failed assertions, got %d\n%s", failed, buf)
+	}
+	expect := `PASS  p99<50ms  (p99 is 10ms)
+PASS  qps>=1000  (qps is 1000)
+FAIL  errors<0.1%  (errors is 0.9901%)
+PASS  errors<=10  (errors is 10)
+FAIL  max<10ms  (max is 10ms)
+`
+	if buf.String() != expect {
+		t.Fatalf("expect\n%s\ngot\n%s", expect, buf)
+	}
+}
+
+func TestAssertionsNoSamples(t *testing.T) {
+	var as Assertions
+	for _, s := range []string{"p99<50ms", "mean<1s", "errors<0.1%", "timeouts<=1%", "errors<=10"} {
+		if err := as.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//every request failed
+	res := testResult(0, 0)
+	res.Requests = 100
+	res.Errors = 100
+	buf := bytes.NewBuffer(nil)
+	if failed := as.Check(buf, res); failed != 4 {
+		t.Fatalf("expect 4 failed assertions, got %d\n%s", failed, buf)
+	}
+	if !strings.Contains(buf.String(), "FAIL  p99<50ms  (no successful requests)") {
+		t.Fatalf("expect the latencies to fail without successful requests\n%s", buf)
+	}
+
+	//no request was sent
+	res = testResult(0, 0)
+	buf.Reset()
+	if failed := as.Check(buf, res); failed != 4 {
+		t.Fatalf("expect 4 failed assertions, got %d\n%s", failed, buf)
+	}
+	if !strings.Contains(buf.String(), "FAIL  errors<0.1%  (no requests)") || !strings.Contains(buf.String(), "PASS  errors<=10") {
+		t.Fatalf("expect the percentages to fail without requests\n%s", buf)
+	}
+}
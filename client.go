@@ -1,7 +1,12 @@
 package fperf
 
 import (
+	"errors"
 	"flag"
+	"io"
+	"log"
+	"sync"
+
 	"golang.org/x/net/context"
 )
 
@@ -16,16 +21,45 @@ type FlagSet struct {
 var clients = make(map[string]NewClientFunc)
 var descriptions = make(map[string]string)
 
-//Parse the command line args
+//flagArgs are the args of the FlagSets created by Run, parsed instead of the command line
+var flagArgs = struct {
+	sync.Mutex
+	m map[*flag.FlagSet][]string
+}{m: make(map[*flag.FlagSet][]string)}
+
+//Parse the command line args, or the Config.Args when the client is created by Run
 func (f *FlagSet) Parse() {
+	flagArgs.Lock()
+	args, ok := flagArgs.m[f.FlagSet]
+	delete(flagArgs.m, f.FlagSet)
+	flagArgs.Unlock()
+	if ok {
+		f.FlagSet.Parse(args)
+		return
+	}
 	f.FlagSet.Parse(flag.Args()[1:])
 }
 
 //NewClient create a client by the name it registered
 func NewClient(name string) Client {
+	return newClient(name, nil)
+}
+
+//newClient create a client whose FlagSet parses args, the command line is used if args is nil
+func newClient(name string, args []string) Client {
 	if c := clients[name]; c != nil {
-		subcmd := &FlagSet{flag.NewFlagSet(flag.Arg(0), flag.ExitOnError)}
-		return c(subcmd)
+		subcmd := &FlagSet{flag.NewFlagSet(name, flag.ExitOnError)}
+		if args != nil {
+			flagArgs.Lock()
+			flagArgs.m[subcmd.FlagSet] = args
+			flagArgs.Unlock()
+		}
+		cli := c(subcmd)
+		//the args are kept only until the client parses them
+		flagArgs.Lock()
+		delete(flagArgs.m, subcmd.FlagSet)
+		flagArgs.Unlock()
+		return cli
 	}
 	return nil
 }
@@ -69,3 +103,96 @@ type Stream interface {
 	DoSend() error
 	DoRecv() error
 }
+
+//SetupClient creates the fixtures of a benchmark like test keys, tables or topics,
+//Setup is called on the first client after all the clients are dialed and before the
+//first request. The benchmark does not run if it fails
+type SetupClient interface {
+	Client
+	Setup(ctx context.Context) error
+}
+
+//TeardownClient cleans up the fixtures, Teardown is called on the first client once
+//the workers exit, even if the benchmark is interrupted. Clients and streams which
+//implement io.Closer are closed after it
+type TeardownClient interface {
+	Client
+	Teardown(ctx context.Context) error
+}
+
+//ContextUnaryClient is a UnaryClient which can be interrupted, RequestContext is called
+//instead of Request with a context canceled at the timeout or the end of the benchmark
+type ContextUnaryClient interface {
+	UnaryClient
+	RequestContext(ctx context.Context) error
+}
+
+//OutcomeClient reports the bytes, the operation and the status of every request,
+//RequestOutcome is called instead of Request and RequestContext with a context
+//canceled at the timeout or the end of the benchmark
+type OutcomeClient interface {
+	UnaryClient
+	RequestOutcome(ctx context.Context) (Outcome, error)
+}
+
+//OperationClient performs the operations named by the entries of a Mix like get in
+//redis:get=70, RequestOperation is called for the entries with an operation
+type OperationClient interface {
+	UnaryClient
+	RequestOperation(ctx context.Context, operation string) (Outcome, error)
+}
+
+//ContextStream is a Stream which can be interrupted, DoSendContext and DoRecvContext are
+//called instead of DoSend and DoRecv with a context canceled at the timeout or the end of
+//the benchmark
+type ContextStream interface {
+	Stream
+	DoSendContext(ctx context.Context) error
+	DoRecvContext(ctx context.Context) error
+}
+
+//ErrTimeout is the error of a request lasting longer than Config.Timeout, a client may
+//also return it, or an error with a Timeout() bool method like net.Error
+var ErrTimeout = errors.New("request timed out")
+
+//isTimeout reports whether err is a timeout
+func isTimeout(err error) bool {
+	if err == ErrTimeout || err == context.DeadlineExceeded {
+		return true
+	}
+	e, ok := err.(interface {
+		Timeout() bool
+	})
+	return ok && e.Timeout()
+}
+
+//unaryCall returns the request of cli taking a context and reporting the outcome, the
+//context is ignored and the outcome is empty unless cli implements the interfaces
+func unaryCall(cli UnaryClient) func(ctx context.Context) (Outcome, error) {
+	switch cli := cli.(type) {
+	case OutcomeClient:
+		return cli.RequestOutcome
+	case ContextUnaryClient:
+		return func(ctx context.Context) (Outcome, error) { return Outcome{}, cli.RequestContext(ctx) }
+	}
+	return func(context.Context) (Outcome, error) { return Outcome{}, cli.Request() }
+}
+
+//streamCalls returns the send and recv of stream taking a context, the context is
+//ignored unless stream is a ContextStream
+func streamCalls(stream Stream) (send, recv func(ctx context.Context) error) {
+	if stream, ok := stream.(ContextStream); ok {
+		return stream.DoSendContext, stream.DoRecvContext
+	}
+	return func(context.Context) error { return stream.DoSend() }, func(context.Context) error { return stream.DoRecv() }
+}
+
+//closeClient closes x if it is an io.Closer, the error is logged since the
+//benchmark is over
+func closeClient(x interface{}, what string) {
+	if c, ok := x.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Printf("close %s: %v\n", what, err)
+		}
+	}
+}
@@ -13,7 +13,8 @@ func init() {
 }
 
 type testpbClient struct {
-	cli testpb.BenchmarkServiceClient
+	conn *grpc.ClientConn
+	cli  testpb.BenchmarkServiceClient
 }
 
 func newTestpbClient(flag *fperf.FlagSet) fperf.Client {
@@ -25,18 +26,29 @@ func (r *testpbClient) Dial(addr string) error {
 	if err != nil {
 		return err
 	}
+	r.conn = conn
 	r.cli = testpb.NewBenchmarkServiceClient(conn)
 	return nil
 }
 
+//Close closes the connection at the end of the benchmark
+func (r *testpbClient) Close() error {
+	return r.conn.Close()
+}
+
 func (r *testpbClient) Request() error {
+	return r.RequestContext(context.Background())
+}
+
+//RequestContext is called instead of Request, the call is canceled with ctx
+func (r *testpbClient) RequestContext(ctx context.Context) error {
 	pl := newPayload(0, 20)
 	sr := &testpb.SimpleRequest{
 		ResponseType: pl.Type,
 		ResponseSize: int32(10),
 		Payload:      pl,
 	}
-	if _, err := r.cli.UnaryCall(context.Background(), sr); err != nil {
+	if _, err := r.cli.UnaryCall(ctx, sr); err != nil {
 		return err
 	}
 	return nil
@@ -84,3 +96,8 @@ func (s *testpbStream) DoRecv() error {
 	_, err := s.stream.Recv()
 	return err
 }
+
+//Close half-closes the stream at the end of the benchmark
+func (s *testpbStream) Close() error {
+	return s.stream.CloseSend()
+}
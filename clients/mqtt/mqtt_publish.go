@@ -115,3 +115,10 @@ func (c *mqttClient) Request() error {
 	}
 	return nil
 }
+
+//Close disconnects from the broker at the end of the benchmark, waiting at most
+//250ms for the publishing in flight
+func (c *mqttClient) Close() error {
+	c.cli.Disconnect(250)
+	return nil
+}
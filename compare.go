@@ -0,0 +1,179 @@
+package fperf
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+//Delta is the change of a metric between two results
+type Delta struct {
+	Name      string
+	Old       float64
+	New       float64
+	Change    float64 //in percent of Old
+	Checked   bool    //whether the metric is compared against the threshold
+	Regressed bool
+}
+
+//Comparison is the differences between a baseline and a new result
+type Comparison struct {
+	Threshold  float64 //in percent
+	Deltas     []Delta
+	SameLayout bool //whether the histograms have the same layout
+}
+
+//defaultCompareMetrics are the metrics checked against the threshold by default,
+//mean and max are too noisy
+var defaultCompareMetrics = []string{"qps", "p50", "p90", "p99", "p99.9"}
+
+//Compare returns the changes of throughput and latencies from base to current, a checked
+//metric regresses if its throughput drops or latency rises by more than threshold percent.
+//All the metrics are checked if metrics is empty
+func Compare(base, current *Result, threshold float64, metrics []string) *Comparison {
+	c := &Comparison{Threshold: threshold, SameLayout: base.Histogram.Opts() == current.Histogram.Opts()}
+	checked := make(map[string]bool)
+	for _, m := range metrics {
+		checked[m] = true
+	}
+	add := func(name string, o, n float64, higherIsBetter bool) {
+		d := Delta{Name: name, Old: o, New: n, Checked: len(metrics) == 0 || checked[name]}
+		switch {
+		case o != 0:
+			d.Change = (n - o) * 100 / o
+		case n != 0:
+			d.Change = math.Inf(1)
+		}
+		if higherIsBetter {
+			d.Regressed = d.Change < -threshold
+		} else {
+			d.Regressed = d.Change > threshold
+		}
+		d.Regressed = d.Regressed && d.Checked
+		c.Deltas = append(c.Deltas, d)
+	}
+	add("qps", base.QPS(), current.QPS(), true)
+	add("mean", base.Histogram.Mean(), current.Histogram.Mean(), false)
+	for _, q := range percentiles {
+		add(percentileName(q), float64(base.Histogram.Percentile(q)), float64(current.Histogram.Percentile(q)), false)
+	}
+	add("max", float64(base.Histogram.Percentile(100)), float64(current.Histogram.Percentile(100)), false)
+	return c
+}
+
+//Regressions returns the number of regressed metrics
+func (c *Comparison) Regressions() int {
+	n := 0
+	for _, d := range c.Deltas {
+		if d.Regressed {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Comparison) has(name string) bool {
+	for _, d := range c.Deltas {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+//Print writes the table of the changes and the verdict
+func (c *Comparison) Print(w io.Writer) {
+	if !c.SameLayout {
+		fmt.Fprintln(w, "the histograms have different layouts, their percentiles are of different precision")
+	}
+	fmt.Fprintf(w, "%-8s  %12s  %12s  %8s\n", "", "baseline", "current", "change")
+	for _, d := range c.Deltas {
+		old, cur := roundDuration(time.Duration(d.Old)).String(), roundDuration(time.Duration(d.New)).String()
+		if d.Name == "qps" {
+			old, cur = fmt.Sprintf("%.1f", d.Old), fmt.Sprintf("%.1f", d.New)
+		}
+		verdict := ""
+		switch {
+		case d.Regressed:
+			verdict = "  REGRESSED"
+		case !d.Checked:
+			verdict = "  (not checked)"
+		}
+		fmt.Fprintf(w, "%-8s  %12s  %12s  %+7.1f%%%s\n", d.Name, old, cur, d.Change, verdict)
+	}
+	if n := c.Regressions(); n > 0 {
+		fmt.Fprintf(w, "FAIL: %d metrics regressed by more than %v%%\n", n, c.Threshold)
+	} else {
+		fmt.Fprintf(w, "PASS: no metric regressed by more than %v%%\n", c.Threshold)
+	}
+}
+
+//readResultFile reads a result saved by -output json
+func readResultFile(name string) (*Result, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	res, err := ReadJSON(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return res, nil
+}
+
+//compareMain runs the compare subcommand and returns the exit code
+func compareMain(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	threshold := fs.Float64("threshold", 10, "percentage of the change regarded as a regression")
+	metrics := fs.String("metrics", strings.Join(defaultCompareMetrics, ","),
+		"comma separated metrics checked against the threshold: qps, mean, p50, p90, p99, p99.9 or max, empty checks all")
+	allowLayouts := fs.Bool("allow-layouts", false, "compare the results of histograms of different layouts, like -hist hdr and -hist milli")
+	fs.Usage = func() {
+		fmt.Printf("Usage: %v compare [options] <baseline.json> <current.json>\noptions:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	base, err := readResultFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	current, err := readResultFile(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var checked []string
+	for _, m := range strings.Split(*metrics, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			checked = append(checked, m)
+		}
+	}
+	c := Compare(base, current, *threshold, checked)
+	for _, m := range checked {
+		if !c.has(m) {
+			fmt.Fprintf(os.Stderr, "unknown metric %q\n", m)
+			return 2
+		}
+	}
+	//the percentiles of different layouts differ by their precision rather than the runs
+	if !c.SameLayout && !*allowLayouts {
+		fmt.Fprintf(os.Stderr, "the histograms have different layouts %+v and %+v, run both with the same -hist or pass -allow-layouts\n",
+			base.Histogram.Opts(), current.Histogram.Opts())
+		return 2
+	}
+	c.Print(os.Stdout)
+	if c.Regressions() > 0 {
+		return exitFailed
+	}
+	return 0
+}
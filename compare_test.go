@@ -0,0 +1,102 @@
+package fperf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	hist "github.com/fperf/fperf/stats"
+)
+
+func testResult(qps int64, latency time.Duration) *Result {
+	h := hist.NewHistogram(DefaultConfig().Histogram)
+	for i := int64(0); i < qps; i++ {
+		h.Add(int64(latency))
+	}
+	return &Result{Elapsed: time.Second, Requests: qps, Histogram: h}
+}
+
+func TestCompare(t *testing.T) {
+	base := testResult(1000, 10*time.Millisecond)
+
+	c := Compare(base, testResult(950, 10500*time.Microsecond), 10, defaultCompareMetrics)
+	if n := c.Regressions(); n != 0 {
+		t.Fatalf("expect no regression within 10%%, got %d", n)
+	}
+
+	c = Compare(base, testResult(800, 12*time.Millisecond), 10, []string{"p99"})
+	for _, d := range c.Deltas {
+		if d.Regressed != (d.Name == "p99") {
+			t.Fatalf("expect only p99 regressed, got %+v", d)
+		}
+	}
+
+	c = Compare(base, testResult(800, 10*time.Millisecond), 10, nil)
+	if n := c.Regressions(); n != 1 || !c.Deltas[0].Regressed || c.Deltas[0].Change > -19 {
+		t.Fatalf("expect the qps regressed by 20%%, got %+v", c.Deltas[0])
+	}
+	buf := bytes.NewBuffer(nil)
+	c.Print(buf)
+	if out := buf.String(); !strings.Contains(out, "REGRESSED") || !strings.Contains(out, "FAIL: 1 metrics") {
+		t.Fatalf("expect the regression reported, got\n%s", out)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	res := testResult(100, time.Millisecond)
+	res.Config = DefaultConfig()
+	buf := bytes.NewBuffer(nil)
+	if err := res.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadJSON(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Requests != 100 || got.Histogram.Percentile(99) != res.Histogram.Percentile(99) {
+		t.Fatalf("expect the result read back, got %+v", got)
+	}
+	if _, err := ReadJSON(strings.NewReader(`{"requests": 1}`)); err == nil {
+		t.Fatal("expect error without histogram")
+	}
+}
+
+func TestCompareLayouts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fperf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, preset string) string {
+		h := hist.NewHistogram(histogramPresets[preset])
+		for i := 0; i < 1000; i++ {
+			h.Add(int64(10 * time.Millisecond))
+		}
+		res := &Result{Config: DefaultConfig(), Elapsed: time.Second, Requests: 1000, Histogram: h}
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := res.WriteJSON(f); err != nil {
+			t.Fatal(err)
+		}
+		return f.Name()
+	}
+	hdr, milli := write("hdr.json", "hdr"), write("milli.json", "milli")
+
+	if c := Compare(testResult(1000, 10*time.Millisecond), testResult(1000, 10*time.Millisecond), 10, nil); !c.SameLayout {
+		t.Fatal("expect the same layout")
+	}
+	if code := compareMain([]string{hdr, milli}); code != 2 {
+		t.Fatalf("expect exit code 2 for different layouts, got %d", code)
+	}
+	if code := compareMain([]string{"-allow-layouts", "-threshold", "50", hdr, milli}); code != 0 {
+		t.Fatalf("expect the comparison allowed by -allow-layouts, got exit code %d", code)
+	}
+}
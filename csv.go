@@ -0,0 +1,60 @@
+package fperf
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+//Exporter receives the statistics of every tick while the benchmark is running
+type Exporter interface {
+	Export(t Tick) error
+}
+
+//StartExporter is an Exporter which needs the configuration of the benchmark, Start
+//is called before the first tick with the call type resolved
+type StartExporter interface {
+	Exporter
+	Start(conf Config) error
+}
+
+//csvExporter writes every tick as a row of CSV
+type csvExporter struct {
+	w      *csv.Writer
+	header bool
+}
+
+//NewCSVExporter returns an Exporter writing the ticks to w as CSV, the latencies
+//are in milliseconds and the elapsed time in seconds. The ticks of the warm-up are
+//marked by the warmup column
+func NewCSVExporter(w io.Writer) Exporter {
+	return &csvExporter{w: csv.NewWriter(w)}
+}
+
+func (e *csvExporter) Export(t Tick) error {
+	if !e.header {
+		e.w.Write([]string{"timestamp", "elapsed_s", "requests", "errors", "qps",
+			"mean_ms", "p50_ms", "p99_ms", "max_ms", "warmup"})
+		e.header = true
+	}
+	e.w.Write([]string{
+		t.Time.Format(time.RFC3339Nano),
+		strconv.FormatFloat(t.Elapsed.Seconds(), 'f', 3, 64),
+		strconv.FormatInt(t.Requests, 10),
+		strconv.FormatInt(t.Errors, 10),
+		strconv.FormatFloat(t.QPS, 'f', 1, 64),
+		milliseconds(t.Mean),
+		milliseconds(t.P50),
+		milliseconds(t.P99),
+		milliseconds(t.Max),
+		strconv.FormatBool(t.Warmup),
+	})
+	//flush every tick so the file can be charted while the benchmark is running
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func milliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
@@ -0,0 +1,54 @@
+package fperf
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestCSVExporter(t *testing.T) {
+	var requests int64
+	registerUnary("test-unary-csv", &requests, false)
+
+	buf := bytes.NewBuffer(nil)
+	conf := DefaultConfig()
+	conf.Target = "test-unary-csv"
+	conf.Delay = time.Millisecond
+	conf.Duration = 100 * time.Millisecond
+	conf.Tick = 20 * time.Millisecond
+	conf.Warmup = Warmup{Duration: 30 * time.Millisecond}
+	conf.Exporters = []Exporter{NewCSVExporter(buf)}
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(res.Ticks)+1 {
+		t.Fatalf("expect a header and %d ticks, got %d rows", len(res.Ticks), len(rows))
+	}
+	if rows[0][0] != "timestamp" || len(rows[0]) != 10 || rows[0][9] != "warmup" {
+		t.Fatalf("unexpected header %v", rows[0])
+	}
+	for i, tick := range res.Ticks {
+		if expect := strconv.FormatBool(tick.Warmup); rows[i+1][9] != expect {
+			t.Fatalf("expect warmup %s in row %d, got %v", expect, i+1, rows[i+1])
+		}
+	}
+	if rows[1][9] != "true" || rows[len(rows)-1][9] != "false" {
+		t.Fatalf("expect the first row of the warm-up and the last measured, got %v", rows)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, rows[1][0]); err != nil {
+		t.Fatal(err)
+	}
+	if rows[1][2] == "0" {
+		t.Fatalf("expect requests in the first tick, got %v", rows[1])
+	}
+}
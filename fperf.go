@@ -37,6 +37,32 @@ Three steps to create your own testcase
 		fperf.Register("demo", NewDemoClient, "This is a demo client discription")
 	}
 
+A client can also implement RequestContext, or DoSendContext and DoRecvContext for a
+stream, to be interrupted at the -timeout of a request or the end of the benchmark
+
+	func (c *DemoClient) RequestContext(ctx context.Context) error {
+		select {
+		case <-time.After(100 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+
+Run a benchmark in your own program
+
+Run takes a typed Config and returns the Result instead of parsing the command line
+
+	conf := fperf.DefaultConfig()
+	conf.Target = "demo"
+	conf.N = 1000
+	res, err := fperf.Run(context.Background(), conf)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	res.Print(os.Stdout)
+
 
 Run the buildin testcase
 
@@ -52,277 +78,39 @@ import (
 	"log"
 	"math/rand"
 	"net/http"
-	_ "net/http/pprof"
 	"os"
 	"os/signal"
 	"runtime"
-	"strings"
-	"sync"
 	"syscall"
-	"time"
 
-	hist "github.com/fperf/fperf/stats"
 	"golang.org/x/net/context"
 )
 
+//setting contains the command line options of Main
 type setting struct {
-	Connection int
-	Stream     int
-	Goroutine  int
+	Config
 	CPU        int
-	Burst      int
-	N          int //number of requests
-	Tick       time.Duration
-	Address    string
-	Send       bool
-	Recv       bool
-	Delay      time.Duration
-	Async      bool
-	Target     string
-	CallType   string
 	Seed       int64
-}
-
-type statistics struct {
-	latencies []time.Duration
-	histogram *hist.Histogram
-}
-
-//roundtrip will be used in async mode
-//the sender and receiver will be in seperate goroutines
-type roundtrip struct {
-	start time.Time
-	end   time.Time
-}
-
-//create the testcase clients, n is the number of clients, set by
-//flag -connection
-func createClients(n int, addr string) []Client {
-	addrs := strings.Split(addr, ";")
-	getAddr := func(addrs []string) func() string {
-		i := -1
-		size := len(addrs)
-		return func() string {
-			i++
-			return addrs[i%size]
-		}
-	}(addrs)
-	clients := make([]Client, n)
-	for i := 0; i < n; i++ {
-		cli := NewClient(s.Target)
-		if cli == nil {
-			log.Fatalf("Can not find client %q for benchmark\n", s.Target)
-		}
-
-		addr := getAddr()
-		if err := cli.Dial(addr); err != nil {
-			log.Fatalln(addr, err)
-		}
-		clients[i] = cli
-	}
-	return clients
-}
-
-//create streams for every client. n is the number of streams per client
-func createStreams(n int, clients []Client) []Stream {
-	streams := make([]Stream, n*len(clients))
-	for cur, cli := range clients {
-		for i := 0; i < n; i++ {
-			if cli, ok := cli.(StreamClient); ok {
-				stream, err := cli.CreateStream(context.Background())
-				if err != nil {
-					log.Fatalf("StreamCall faile to create new stream, %v", err)
-				}
-				streams[cur*n+i] = stream
-			} else {
-				log.Fatalln(s.Target, " do not implement the fperf.StreamClient")
-			}
-		}
-	}
-	return streams
-}
-
-//run benchmark for stream clients, can be in async or sync mode
-func benchmarkStream(n int, streams []Stream) {
-	var wg sync.WaitGroup
-	for _, stream := range streams {
-		for i := 0; i < n; i++ {
-			//Notice here. we must pass stream as a parameter because the varibale stream
-			//would be changed after the goroutine created
-			if s.Async {
-				wg.Add(2)
-				go func(stream Stream) { send(nil, stream); wg.Done() }(stream)
-				go func(stream Stream) { recv(nil, stream); wg.Done() }(stream)
-			} else {
-				wg.Add(1)
-				go func(stream Stream) { run(nil, stream); wg.Done() }(stream)
-			}
-		}
-	}
-	go statPrint()
-	wg.Wait()
-}
-
-//run benchmark for unary clients
-func benchmarkUnary(n int, clients []Client) {
-	var wg sync.WaitGroup
-	for _, cli := range clients {
-		for i := 0; i < n; i++ {
-			wg.Add(1)
-			if cli, ok := cli.(UnaryClient); ok {
-				go func(cli UnaryClient) { runUnary(nil, cli); wg.Done() }(cli)
-			} else {
-				log.Fatalln(s.Target, " does not implement the fperf.UnaryClient")
-			}
-		}
-	}
-	go statPrint()
-	wg.Wait()
-}
-
-func runUnary(done <-chan int, cli UnaryClient) {
-	for i := 0; s.N == 0 || i < s.N; i++ {
-		//select {
-		//case <-done:
-		//	log.Println("run goroutine exit done")
-		//	return
-		//default:
-		start := time.Now()
-		if err := cli.Request(); err != nil {
-			log.Println(err)
-		}
-		eplase := time.Since(start)
-		stats.latencies = append(stats.latencies, eplase)
-		if s.Delay > 0 {
-			time.Sleep(s.Delay)
-		}
-		//	}
-	}
-}
-func run(done <-chan int, stream Stream) {
-	for i := 0; s.N == 0 || i < s.N; i++ {
-		select {
-		case <-done:
-			log.Println("run goroutine exit done")
-			return
-		default:
-			start := time.Now()
-			if s.Send {
-				stream.DoSend()
-			}
-			if s.Recv {
-				stream.DoRecv()
-			}
-			eplase := time.Since(start)
-			stats.latencies = append(stats.latencies, eplase)
-			if s.Delay > 0 {
-				time.Sleep(s.Delay)
-			}
-		}
-	}
-}
-
-func send(done <-chan int, stream Stream) {
-	timer := time.NewTimer(time.Second)
-	for i := 0; s.N == 0 || i < s.N; i++ {
-		select {
-		case <-done:
-			log.Println("send goroutine exit, done")
-			return
-		default:
-			select {
-			case rtts <- &roundtrip{start: time.Now()}:
-				timer.Reset(time.Second)
-			case <-timer.C:
-				log.Println("blocked on send rtts")
-			}
-
-			if burst != nil {
-				select {
-				case burst <- 0:
-					timer.Reset(time.Second)
-				case <-timer.C:
-					log.Println("blocked on send burst chan")
-				}
-			}
-
-			stream.DoSend()
-			if s.Delay > 0 {
-				time.Sleep(s.Delay)
-			}
-		}
-	}
-}
-func recv(done <-chan int, stream Stream) {
-	timer := time.NewTimer(time.Second)
-	for i := 0; s.N == 0 || i < s.N; i++ {
-		select {
-		case <-done:
-			log.Println("recv goroutine exit, done")
-			return
-		default:
-			if burst != nil {
-				select {
-				case <-burst:
-					timer.Reset(time.Second)
-				case <-timer.C:
-					log.Println("blocked on recv burst chan")
-				}
-			}
-			err := stream.DoRecv()
-			if err != nil {
-				log.Println("recv goroutine exit", err)
-				return
-			}
-			select {
-			case rtt := <-rtts:
-				timer.Reset(time.Second)
-				eplase := time.Since(rtt.start)
-				stats.latencies = append(stats.latencies, eplase)
-			case <-timer.C:
-				log.Println("blocked on recv rtts")
-			}
-			if s.Delay > 0 {
-				time.Sleep(s.Delay)
-			}
-		}
-	}
-}
-
-func statPrint() {
-	tickc := time.Tick(s.Tick)
-	var latencies []time.Duration
-	total := int64(0)
-	for {
-		select {
-		case <-tickc:
-			latencies = stats.latencies
-			stats.latencies = stats.latencies[:0]
-
-			sum := time.Duration(0)
-			for _, eplase := range latencies {
-				total++
-				sum += eplase
-				stats.histogram.Add(int64(eplase))
-			}
-			count := len(latencies)
-			if count != 0 {
-				log.Printf("latency %v qps %d total %v\n", sum/time.Duration(count), int64(float64(count)/float64(s.Tick)*float64(time.Second)), total)
-			} else {
-				log.Printf("blocking...")
-			}
-		}
-	}
+	Hist       histogramFlags
+	Output     string
+	OutputFile string
+	Report     string
+	Assertions Assertions
+	Search     Search
+	CSV        string
+	InfluxDB   string
+	Tags       Tags
+	Profile    profiling
 }
 
 var s setting
-var stats statistics
-var rtts = make(chan *roundtrip, 10*1024*1024)
-var mutex sync.RWMutex
-var burst chan int
+
+//exitFailed is the exit code when the result fails its checks, to tell it from the errors
+const exitFailed = 3
 
 func usage() {
-	fmt.Printf("Usage: %v [options] <client>\noptions:\n", os.Args[0])
+	fmt.Printf("Usage: %v [options] <client>\n       %v -mix <client:operation=weight,...> [options] [client options]\n"+
+		"       %v compare [options] <baseline.json> <current.json>\noptions:\n", os.Args[0], os.Args[0], os.Args[0])
 	flag.PrintDefaults()
 	fmt.Println("clients:")
 	for name, desc := range AllClients() {
@@ -334,72 +122,194 @@ func usage() {
 	}
 }
 
+//Main parses the command line and runs the benchmark
 func Main() {
-	flag.IntVar(&s.Connection, "connection", 1, "number of connection")
-	flag.IntVar(&s.Stream, "stream", 1, "number of streams per connection")
-	flag.IntVar(&s.Goroutine, "goroutine", 1, "number of goroutines per stream")
+	s.Config = DefaultConfig()
+	flag.IntVar(&s.Connection, "connection", s.Connection, "number of connection")
+	flag.IntVar(&s.Stream, "stream", s.Stream, "number of streams per connection")
+	flag.IntVar(&s.Goroutine, "goroutine", s.Goroutine, "number of goroutines per stream")
 	flag.IntVar(&s.CPU, "cpu", 0, "set the GOMAXPROCS, use go default if 0")
 	flag.IntVar(&s.Burst, "burst", 0, "burst a number of request, use with -async=true")
 	flag.IntVar(&s.N, "N", 0, "number of request per goroutine")
-	flag.BoolVar(&s.Send, "send", true, "perform send action")
-	flag.BoolVar(&s.Recv, "recv", true, "perform recv action")
+	flag.DurationVar(&s.Duration, "duration", 0, "stop the benchmark after the duration, 0 means run until interrupted")
+	flag.Var(&s.Stages, "stages", "load profile ramping the workers like 30s:10,2m:200,30s:0 or the rate like 30s:100/s,2m:1000/s, the benchmark stops at its end")
+	flag.Var(&s.Search, "search", "search the maximum throughput meeting the -assert SLOs by stepping the goroutines like 10:10:200 or the rate like 100/s:100/s:5000/s, every step lasts -duration, 10s by default")
+	flag.Var(&s.Mix, "mix", "send the requests by several clients or operations by weight like redis:get=70,redis:set=30, or @file.json, instead of a single client")
+	flag.Var(&s.Warmup, "warmup", "discard the samples of a warm-up duration like 10s or number of requests like 1000, included in -duration and -N")
+	flag.BoolVar(&s.Send, "send", s.Send, "perform send action")
+	flag.BoolVar(&s.Recv, "recv", s.Recv, "perform recv action")
 	flag.DurationVar(&s.Delay, "delay", 0, "wait delay time before send the next request")
-	flag.DurationVar(&s.Tick, "tick", 2*time.Second, "interval between statistics")
-	flag.StringVar(&s.Address, "server", "127.0.0.1:8804", "address of the target server")
+	flag.DurationVar(&s.Timeout, "timeout", 0, "fail a request lasting longer as a timeout, the clients implementing the context interfaces are interrupted, 0 means unlimited")
+	flag.BoolVar(&s.Correct, "correct", false, "correct coordinated omission by back-filling the samples a stalled request hides, expecting a request every median latency plus -delay")
+	flag.Var(&s.Rate, "rate", "send requests at a constant aggregate rate like 5000/s regardless of the responses, 0 means as fast as possible")
+	flag.DurationVar(&s.Tick, "tick", s.Tick, "interval between statistics")
+	flag.StringVar(&s.Address, "server", s.Address, "address of the target server")
 	flag.BoolVar(&s.Async, "async", false, "send and recv in seperate goroutines")
-	flag.StringVar(&s.CallType, "type", "auto", "set the call type:unary, stream or auto. default is auto")
+	flag.StringVar(&s.CallType, "type", s.CallType, "set the call type:unary, stream or auto. default is auto")
 	flag.Int64Var(&s.Seed, "seed", 0, "seed of the global math/rand")
+	flag.StringVar(&s.Hist.preset, "hist", "hdr", "histogram layout preset: hdr, micro, milli or seconds")
+	flag.IntVar(&s.Hist.digits, "hist-digits", 3, "significant digits of the hdr histogram, at most 5")
+	flag.IntVar(&s.Hist.buckets, "hist-buckets", 0, "number of buckets, overrides the bucket preset")
+	flag.Float64Var(&s.Hist.growth, "hist-growth", 0, "growth factor of the buckets, overrides the bucket preset")
+	flag.Float64Var(&s.Hist.base, "hist-base", 0, "size of the first bucket in nanoseconds, overrides the bucket preset")
+	flag.Int64Var(&s.Hist.min, "hist-min", 0, "lower bound of the first bucket in nanoseconds, overrides the bucket preset")
+	flag.StringVar(&s.Output, "output", "text", "format of the final report: text, json or html")
+	flag.StringVar(&s.OutputFile, "output-file", "", "write the final report to the file instead of stdout")
+	flag.StringVar(&s.Report, "report", "", "also write the final report as a self-contained HTML page to the file")
+	flag.Var(&s.Assertions, "assert", "fail with exit code 3 unless the result meets the condition like p99<50ms, errors<0.1% or qps>2000, can be set multiple times")
+	flag.StringVar(&s.CSV, "csv", "", "write the statistics of every tick to the CSV file")
+	flag.StringVar(&s.InfluxDB, "influxdb", "", "push the statistics of every tick to influxdb, like http://127.0.0.1:8086/write?db=fperf or udp://127.0.0.1:8089")
+	s.Tags = make(Tags)
+	flag.Var(s.Tags, "tag", "key=value tag of the exported metrics, can be set multiple times")
+	flag.StringVar(&s.Profile.debugAddr, "debug-addr", ":6060", "address of the debug server serving pprof and /metrics, empty disables it")
+	flag.IntVar(&s.Profile.blockRate, "block-profile-rate", 0, "enable the block profile, see runtime.SetBlockProfileRate")
+	flag.IntVar(&s.Profile.mutexFraction, "mutex-profile-fraction", 0, "enable the mutex profile, see runtime.SetMutexProfileFraction")
+	flag.StringVar(&s.Profile.cpuFile, "cpu-profile", "", "write the CPU profile of the benchmark to the file")
+	flag.StringVar(&s.Profile.memFile, "mem-profile", "", "write the heap profile to the file at the end of the benchmark")
 	flag.Usage = usage
 	flag.Parse()
 
-	s.Target = flag.Arg(0)
-	if len(s.Target) == 0 {
-		flag.Usage()
-		return
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	histopts, err := s.Hist.options(set)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	s.Histogram = histopts
+	output := outputFormats[s.Output]
+	if output == nil {
+		log.Fatalf("unknown output format %q\n", s.Output)
+	}
+	if s.Search.Step > 0 && (s.Output == "html" || s.Report != "") {
+		log.Fatalln("search supports the text and json output only")
+	}
+
+	if flag.Arg(0) == "compare" {
+		os.Exit(compareMain(flag.Args()[1:]))
+	}
+	if len(s.Mix) > 0 {
+		//all the args are parsed by the clients of the mix
+		s.Target = s.Mix.String()
+		s.Args = flag.Args()
+	} else {
+		s.Target = flag.Arg(0)
+		if len(s.Target) == 0 {
+			flag.Usage()
+			return
+		}
+		s.Args = flag.Args()[1:]
+	}
+
+	w := os.Stdout
+	if s.OutputFile != "" {
+		f, err := os.Create(s.OutputFile)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+		w = f
 	}
+	if s.CSV != "" {
+		f, err := os.Create(s.CSV)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+		s.Exporters = append(s.Exporters, NewCSVExporter(f))
+	}
+	if s.InfluxDB != "" {
+		tags := Tags{"client": s.Target}
+		for k, v := range s.Tags {
+			tags[k] = v
+		}
+		e, err := NewInfluxDBExporter(s.InfluxDB, tags)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		s.Exporters = append(s.Exporters, e)
+	}
+	//scraped from the debug server, see -debug-addr
+	metrics := NewPrometheusExporter(s.Tags)
+	s.Exporters = append(s.Exporters, metrics)
+	http.Handle("/metrics", metrics)
+
+	//the first signal stops the benchmark gracefully, the second one exits immediately
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		_ = <-c
-		stats.histogram.Print(os.Stdout)
-		os.Exit(0)
+		cancel()
+		_ = <-c
+		os.Exit(1)
 	}()
 
 	runtime.GOMAXPROCS(s.CPU)
-	go func() {
-		runtime.SetBlockProfileRate(1)
-		log.Println(http.ListenAndServe(":6060", nil))
-	}()
+	if err := s.Profile.start(); err != nil {
+		log.Fatalln(err)
+	}
 
 	rand.Seed(s.Seed)
 
-	if s.Burst > 0 {
-		burst = make(chan int, s.Burst)
+	if s.Search.Step > 0 {
+		if s.Duration == 0 {
+			s.Duration = defaultSearchStep
+		}
+		sr, err := RunSearch(ctx, s.Config, s.Search, s.Assertions)
+		closeExporters(s.Exporters)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := s.Profile.stop(); err != nil {
+			log.Println(err)
+		}
+		if s.Output == "json" {
+			err = sr.WriteJSON(w)
+		} else {
+			sr.Print(w)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if sr.Max < 0 {
+			os.Exit(exitFailed)
+		}
+		return
 	}
 
-	stats.latencies = make([]time.Duration, 0, 500000)
-	histopt := hist.HistogramOptions{
-		NumBuckets:     16,
-		GrowthFactor:   1.8,
-		BaseBucketSize: 1000,
-		MinValue:       10000,
+	res, err := Run(ctx, s.Config)
+	closeExporters(s.Exporters)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := s.Profile.stop(); err != nil {
+		log.Println(err)
 	}
-	stats.histogram = hist.NewHistogram(histopt)
-	clients := createClients(s.Connection, s.Address)
-	cli := clients[0]
-	switch s.CallType {
-	case "auto":
-		switch cli.(type) {
-		case StreamClient:
-			streams := createStreams(s.Stream, clients)
-			benchmarkStream(s.Goroutine, streams)
-		case UnaryClient:
-			benchmarkUnary(s.Goroutine, clients)
+	if err := output(w, res); err != nil {
+		log.Fatalln(err)
+	}
+	if s.Report != "" {
+		f, err := os.Create(s.Report)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+		if err := res.WriteHTML(f); err != nil {
+			log.Fatalln(err)
 		}
-	case "stream":
-		streams := createStreams(s.Stream, clients)
-		benchmarkStream(s.Goroutine, streams)
-	case "unary":
-		benchmarkUnary(s.Goroutine, clients)
+	}
+	//stderr keeps the JSON output on stdout valid
+	if failed := s.Assertions.Check(os.Stderr, res); failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d assertions failed\n", failed, len(s.Assertions))
+		os.Exit(exitFailed)
+	}
+}
+
+//closeExporters flushes and closes the exporters implementing io.Closer once the
+//benchmark is over, before the exits by os.Exit skip the deferred calls
+func closeExporters(exporters []Exporter) {
+	for _, e := range exporters {
+		closeClient(e, "exporter")
 	}
 }
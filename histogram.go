@@ -0,0 +1,83 @@
+package fperf
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	hist "github.com/fperf/fperf/stats"
+)
+
+//histogramPresets are the histogram layouts which can be selected by -hist
+var histogramPresets = map[string]hist.HistogramOptions{
+	//high dynamic range with 3 significant digits from 1ns to hours
+	"hdr": {SignificantDigits: 3},
+	//1µs buckets growing by 25% up to about 170ms, for sub-millisecond calls like redis
+	"micro": {NumBuckets: 56, GrowthFactor: 0.25, BaseBucketSize: 1e3},
+	//100µs buckets growing by 30% up to about 8s
+	"milli": {NumBuckets: 45, GrowthFactor: 0.3, BaseBucketSize: 1e5},
+	//10ms buckets growing by 30% up to about 13min, for multi-second calls
+	"seconds": {NumBuckets: 45, GrowthFactor: 0.3, BaseBucketSize: 1e7},
+}
+
+//histogramFlags are the flags overriding the preset of the histogram layout
+type histogramFlags struct {
+	preset  string
+	digits  int
+	buckets int
+	growth  float64
+	base    float64
+	min     int64
+}
+
+//options returns the histogram layout of the preset with the flags in set overriding it
+func (f *histogramFlags) options(set map[string]bool) (hist.HistogramOptions, error) {
+	opts, ok := histogramPresets[f.preset]
+	if !ok {
+		names := make([]string, 0, len(histogramPresets))
+		for name := range histogramPresets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return opts, fmt.Errorf("unknown histogram preset %q, should be one of %s", f.preset, strings.Join(names, ", "))
+	}
+	if set["hist-digits"] {
+		if opts.SignificantDigits == 0 {
+			return opts, fmt.Errorf("-hist-digits needs the hdr preset")
+		}
+		if f.digits <= 0 {
+			return opts, fmt.Errorf("-hist-digits should be greater than 0")
+		}
+		opts.SignificantDigits = f.digits
+	}
+	for _, name := range []string{"hist-buckets", "hist-growth", "hist-base", "hist-min"} {
+		if set[name] && opts.SignificantDigits > 0 {
+			return opts, fmt.Errorf("-%s needs a bucket preset like -hist milli", name)
+		}
+	}
+	if set["hist-buckets"] {
+		opts.NumBuckets = f.buckets
+	}
+	if set["hist-growth"] {
+		opts.GrowthFactor = f.growth
+	}
+	if set["hist-base"] {
+		opts.BaseBucketSize = f.base
+	}
+	if set["hist-min"] {
+		opts.MinValue = f.min
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, fmt.Errorf("invalid histogram: %v", err)
+	}
+	return opts, nil
+}
+
+//addLatency adds a latency to h, the error of a negative latency is logged
+func addLatency(h *hist.Histogram, eplase time.Duration) {
+	if err := h.Add(int64(eplase)); err != nil {
+		log.Println("histogram:", err)
+	}
+}
@@ -0,0 +1,83 @@
+package fperf
+
+import (
+	"testing"
+	"time"
+
+	hist "github.com/fperf/fperf/stats"
+)
+
+func TestHistogramPresets(t *testing.T) {
+	//the largest latency each preset should still resolve
+	ranges := map[string]time.Duration{
+		"micro":   150 * time.Millisecond,
+		"milli":   7 * time.Second,
+		"seconds": 10 * time.Minute,
+	}
+	for name, opts := range histogramPresets {
+		if err := opts.Validate(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if max, ok := ranges[name]; ok {
+			h := hist.NewHistogram(opts)
+			if err := h.Add(int64(max)); err != nil {
+				t.Fatalf("%s: expect %v in range, %v", name, max, err)
+			}
+		}
+		//the values above the range are kept in the last bucket
+		h := hist.NewHistogram(opts)
+		for i := 0; i < 98; i++ {
+			h.Add(int64(time.Millisecond))
+		}
+		over := int64(time.Hour)
+		for _, v := range []int64{over, 2 * over} {
+			if err := h.Add(v); err != nil {
+				t.Fatalf("%s: expect %v counted, %v", name, time.Duration(v), err)
+			}
+		}
+		if h.Count != 100 || h.Max != 2*over || h.Percentile(99) < over {
+			t.Fatalf("%s: expect the values above the range counted, count %d max %v p99 %v",
+				name, h.Count, time.Duration(h.Max), time.Duration(h.Percentile(99)))
+		}
+	}
+}
+
+func TestHistogramFlags(t *testing.T) {
+	f := &histogramFlags{preset: "hdr", digits: 2}
+	opts, err := f.options(map[string]bool{"hist-digits": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.SignificantDigits != 2 {
+		t.Fatalf("expect 2 significant digits, got %d", opts.SignificantDigits)
+	}
+
+	f = &histogramFlags{preset: "milli", buckets: 20, min: 1000}
+	opts, err = f.options(map[string]bool{"hist-buckets": true, "hist-min": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := histogramPresets["milli"]
+	expect.NumBuckets = 20
+	expect.MinValue = 1000
+	if opts != expect {
+		t.Fatalf("expect %+v, got %+v", expect, opts)
+	}
+
+	invalid := []struct {
+		f   histogramFlags
+		set map[string]bool
+	}{
+		{histogramFlags{preset: "nano"}, nil},
+		{histogramFlags{preset: "hdr", buckets: 10}, map[string]bool{"hist-buckets": true}},
+		{histogramFlags{preset: "milli", digits: 3}, map[string]bool{"hist-digits": true}},
+		{histogramFlags{preset: "hdr", digits: 0}, map[string]bool{"hist-digits": true}},
+		{histogramFlags{preset: "hdr", digits: 9}, map[string]bool{"hist-digits": true}},
+		{histogramFlags{preset: "milli", growth: -1}, map[string]bool{"hist-growth": true}},
+	}
+	for _, c := range invalid {
+		if _, err := c.f.options(c.set); err == nil {
+			t.Errorf("%+v %v: expect error", c.f, c.set)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package fperf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//the size of a chart and the margins around its plot area
+const (
+	chartWidth  = 860
+	chartHeight = 280
+	chartLeft   = 70
+	chartRight  = 20
+	chartTop    = 40
+	chartBottom = 40
+)
+
+//series is a line of a chart
+type series struct {
+	name   string
+	color  string
+	values []float64
+}
+
+//htmlReport is the data of htmlTemplate
+type htmlReport struct {
+	*Result
+	Elapsed      time.Duration
+	QPS          string
+	ErrorRate    string
+	Throughput   template.HTML
+	Latency      template.HTML
+	Distribution template.HTML
+	Columns      []string
+	Rows         []htmlRow
+	Stages       []htmlStage
+	Operations   []htmlOperation
+	Outcomes     []htmlOutcome
+	Transfer     string //the transfer rates, empty if no outcome reports the bytes
+	Failures     []htmlFailure
+	Settings     string
+}
+
+type htmlRow struct {
+	Name   string
+	Values []time.Duration
+}
+
+type htmlStage struct {
+	Index    int
+	Stage    Stage
+	Requests int64
+	Errors   int64
+	QPS      string
+	P50      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+}
+
+type htmlOperation struct {
+	Name     string
+	Requests int64
+	Errors   int64
+	QPS      string
+	P50      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+}
+
+type htmlOutcome struct {
+	Name     string
+	Requests int64
+	Failed   int64
+	P50      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+}
+
+type htmlFailure struct {
+	Message string
+	Count   int64
+	Percent string
+}
+
+//WriteHTML writes the result as a self-contained HTML page with the charts of the
+//throughput and latencies over time, the latency distribution, the percentile table,
+//the errors and the configuration
+func (res *Result) WriteHTML(w io.Writer) error {
+	settings, err := json.MarshalIndent(res.Config, "", "  ")
+	if err != nil {
+		return err
+	}
+	report := htmlReport{
+		Result:    res,
+		Elapsed:   roundDuration(res.Elapsed),
+		QPS:       strconv.FormatFloat(res.QPS(), 'f', 1, 64),
+		ErrorRate: percent(res.Errors, res.Requests),
+		Settings:  string(settings),
+	}
+
+	elapsed := make([]float64, len(res.Ticks))
+	qps := series{name: "qps", color: "#1f77b4", values: make([]float64, len(res.Ticks))}
+	errs := series{name: "errors/s", color: "#d62728", values: make([]float64, len(res.Ticks))}
+	mean := series{name: "mean", color: "#1f77b4"}
+	p50 := series{name: "p50", color: "#2ca02c"}
+	p99 := series{name: "p99", color: "#ff7f0e"}
+	maximum := series{name: "max", color: "#d62728"}
+	corrected := series{name: "corrected", color: "#9467bd"}
+	for i, t := range res.Ticks {
+		elapsed[i] = t.Elapsed.Seconds()
+		qps.values[i] = t.QPS
+		if t.Interval > 0 {
+			errs.values[i] = float64(t.Errors) / t.Interval.Seconds()
+		}
+		mean.values = append(mean.values, float64(t.Mean)/float64(time.Millisecond))
+		p50.values = append(p50.values, float64(t.P50)/float64(time.Millisecond))
+		p99.values = append(p99.values, float64(t.P99)/float64(time.Millisecond))
+		maximum.values = append(maximum.values, float64(t.Max)/float64(time.Millisecond))
+		corrected.values = append(corrected.values, float64(t.Corrected)/float64(time.Millisecond))
+	}
+	report.Throughput = lineChart("Throughput", "/s", elapsed, []series{qps, errs})
+	latencies := []series{mean, p50, p99, maximum}
+	if res.Corrected != nil {
+		latencies = append(latencies, corrected)
+	}
+	report.Latency = lineChart("Latency", "ms", elapsed, latencies)
+	report.Distribution = res.distributionChart()
+
+	columns, rows := res.summary()
+	report.Columns = columns
+	for _, r := range rows {
+		report.Rows = append(report.Rows, htmlRow{Name: r.name, Values: r.values})
+	}
+	for i, stage := range res.Stages {
+		h := stage.Histogram
+		report.Stages = append(report.Stages, htmlStage{
+			Index:    i + 1,
+			Stage:    stage.Stage,
+			Requests: stage.Requests,
+			Errors:   stage.Errors,
+			QPS:      strconv.FormatFloat(stage.QPS(), 'f', 1, 64),
+			P50:      roundDuration(time.Duration(h.Percentile(50))),
+			P99:      roundDuration(time.Duration(h.Percentile(99))),
+			Max:      roundDuration(time.Duration(h.Percentile(100))),
+		})
+	}
+	for _, o := range res.Operations {
+		h := o.Histogram
+		report.Operations = append(report.Operations, htmlOperation{
+			Name:     o.Name,
+			Requests: o.Requests,
+			Errors:   o.Errors,
+			QPS:      strconv.FormatFloat(o.QPS(res.Elapsed), 'f', 1, 64),
+			P50:      roundDuration(time.Duration(h.Percentile(50))),
+			P99:      roundDuration(time.Duration(h.Percentile(99))),
+			Max:      roundDuration(time.Duration(h.Percentile(100))),
+		})
+	}
+	for _, o := range res.Outcomes {
+		h := o.Histogram
+		report.Outcomes = append(report.Outcomes, htmlOutcome{
+			Name:     o.name(),
+			Requests: o.Requests,
+			Failed:   o.Failed,
+			P50:      roundDuration(time.Duration(h.Percentile(50))),
+			P99:      roundDuration(time.Duration(h.Percentile(99))),
+			Max:      roundDuration(time.Duration(h.Percentile(100))),
+		})
+	}
+	if res.Sent > 0 || res.Received > 0 {
+		report.Transfer = fmt.Sprintf("Sent %.2f MB/s, received %.2f MB/s", megabytes(res.Sent, res.Elapsed),
+			megabytes(res.Received, res.Elapsed))
+	}
+	for _, msg := range res.failureMessages() {
+		n := res.Failures[msg]
+		report.Failures = append(report.Failures, htmlFailure{Message: msg, Count: n, Percent: percent(n, res.Errors)})
+	}
+	return htmlTemplate.Execute(w, report)
+}
+
+//distributionChart draws the percentage of the requests in every bucket of the histogram
+func (res *Result) distributionChart() template.HTML {
+	h := res.Histogram
+	if h.Count == 0 {
+		return lineChart("Latency distribution", "%", nil, nil)
+	}
+	buckets, _ := h.DisplayBuckets()
+	//only the range between the first and the last non-empty buckets
+	first, last := 0, len(buckets)-1
+	for first < last && buckets[first].Count == 0 {
+		first++
+	}
+	for last > first && buckets[last].Count == 0 {
+		last--
+	}
+	var labels []string
+	var values []float64
+	for _, b := range buckets[first : last+1] {
+		labels = append(labels, roundDuration(time.Duration(b.LowBound)).String())
+		values = append(values, float64(b.Count)*100/float64(h.Count))
+	}
+	return barChart("Latency distribution", "%", labels, values)
+}
+
+//lineChart draws the lines over xs, the elapsed seconds, as an inline SVG
+func lineChart(title, unit string, xs []float64, lines []series) template.HTML {
+	var buf bytes.Buffer
+	ymax := 0.0
+	for _, s := range lines {
+		for _, v := range s.values {
+			ymax = math.Max(ymax, v)
+		}
+	}
+	ymax = niceCeil(ymax)
+	xmax := 0.0
+	if len(xs) > 0 {
+		xmax = xs[len(xs)-1]
+	}
+	xmax = niceCeil(xmax)
+	plotWidth := float64(chartWidth - chartLeft - chartRight)
+	plotHeight := float64(chartHeight - chartTop - chartBottom)
+
+	beginChart(&buf, title, unit, ymax)
+	for i := 0; i <= 4; i++ {
+		x := chartLeft + plotWidth*float64(i)/4
+		fmt.Fprintf(&buf, `<text x="%.1f" y="%d" text-anchor="middle">%ss</text>`,
+			x, chartHeight-chartBottom+18, formatNumber(xmax*float64(i)/4))
+	}
+	for i, s := range lines {
+		points := make([]string, len(s.values))
+		for j, v := range s.values {
+			points[j] = fmt.Sprintf("%.1f,%.1f", chartLeft+plotWidth*xs[j]/xmax, chartTop+plotHeight*(1-v/ymax))
+		}
+		fmt.Fprintf(&buf, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`,
+			s.color, strings.Join(points, " "))
+		//legend on the top right
+		x := chartWidth - chartRight - 90*(len(lines)-i)
+		fmt.Fprintf(&buf, `<rect x="%d" y="12" width="12" height="12" fill="%s"/><text x="%d" y="22">%s</text>`,
+			x, s.color, x+16, html.EscapeString(s.name))
+	}
+	buf.WriteString("</svg>")
+	return template.HTML(buf.String())
+}
+
+//barChart draws a bar of every value as an inline SVG
+func barChart(title, unit string, labels []string, values []float64) template.HTML {
+	var buf bytes.Buffer
+	ymax := 0.0
+	for _, v := range values {
+		ymax = math.Max(ymax, v)
+	}
+	ymax = niceCeil(ymax)
+	plotWidth := float64(chartWidth - chartLeft - chartRight)
+	plotHeight := float64(chartHeight - chartTop - chartBottom)
+
+	beginChart(&buf, title, unit, ymax)
+	width := plotWidth / float64(len(values))
+	//label about 10 bars to keep them readable
+	every := (len(values) + 9) / 10
+	for i, v := range values {
+		x := chartLeft + width*float64(i)
+		height := plotHeight * v / ymax
+		fmt.Fprintf(&buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#1f77b4"><title>%s %.2f%%</title></rect>`,
+			x+1, chartTop+plotHeight-height, math.Max(width-2, 1), height, html.EscapeString(labels[i]), v)
+		if i%every == 0 {
+			fmt.Fprintf(&buf, `<text x="%.1f" y="%d">%s</text>`,
+				x, chartHeight-chartBottom+18, html.EscapeString(labels[i]))
+		}
+	}
+	buf.WriteString("</svg>")
+	return template.HTML(buf.String())
+}
+
+//beginChart opens the SVG and draws the title and the horizontal grid up to ymax
+func beginChart(buf *bytes.Buffer, title, unit string, ymax float64) {
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(buf, `<text x="%d" y="22" class="title">%s</text>`, chartLeft, html.EscapeString(title))
+	plotHeight := float64(chartHeight - chartTop - chartBottom)
+	for i := 0; i <= 4; i++ {
+		y := chartTop + plotHeight*float64(4-i)/4
+		fmt.Fprintf(buf, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`,
+			chartLeft, y, chartWidth-chartRight, y)
+		fmt.Fprintf(buf, `<text x="%d" y="%.1f" text-anchor="end">%s%s</text>`,
+			chartLeft-6, y+4, formatNumber(ymax*float64(i)/4), html.EscapeString(unit))
+	}
+}
+
+//niceCeil rounds v up to 1, 2 or 5 times a power of 10 to get readable axis labels
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5} {
+		if m*exp >= v {
+			return m * exp
+		}
+	}
+	return 10 * exp
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>fperf {{.Config.Target}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+td.message { text-align: left; font-family: monospace; }
+svg { display: block; margin-bottom: 1em; font-size: 12px; }
+svg .title { font-size: 15px; font-weight: bold; }
+pre { background: #f6f6f6; padding: 1em; }
+</style>
+</head>
+<body>
+<h1>fperf {{.Config.Target}}</h1>
+<table>
+<tr><th>Start</th><th>Elapsed</th><th>Requests</th><th>Errors</th><th>QPS</th></tr>
+<tr><td>{{.Start.Format "2006-01-02 15:04:05 MST"}}</td><td>{{.Elapsed}}</td><td>{{.Requests}}</td><td>{{.Errors}} ({{.ErrorRate}}){{if .Timeouts}}, {{.Timeouts}} timeouts{{end}}</td><td>{{.QPS}}</td></tr>
+</table>
+{{if .Transfer}}<p>{{.Transfer}}</p>
+{{end}}{{.Throughput}}
+{{.Latency}}
+{{.Distribution}}
+<h2>Percentiles</h2>
+<table>
+<tr><th></th>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><th>{{.Name}}</th>{{range .Values}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{if .Stages}}<h2>Stages</h2>
+<table>
+<tr><th>Stage</th><th>Target</th><th>Requests</th><th>Errors</th><th>QPS</th><th>p50</th><th>p99</th><th>max</th></tr>
+{{range .Stages}}<tr><td>{{.Index}}</td><td>{{.Stage}}</td><td>{{.Requests}}</td><td>{{.Errors}}</td><td>{{.QPS}}</td><td>{{.P50}}</td><td>{{.P99}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Operations}}<h2>Operations</h2>
+<table>
+<tr><th>Operation</th><th>Requests</th><th>Errors</th><th>QPS</th><th>p50</th><th>p99</th><th>max</th></tr>
+{{range .Operations}}<tr><td class="message">{{.Name}}</td><td>{{.Requests}}</td><td>{{.Errors}}</td><td>{{.QPS}}</td><td>{{.P50}}</td><td>{{.P99}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Outcomes}}<h2>By status</h2>
+<table>
+<tr><th>Status</th><th>Requests</th><th>Failed</th><th>p50</th><th>p99</th><th>max</th></tr>
+{{range .Outcomes}}<tr><td class="message">{{.Name}}</td><td>{{.Requests}}</td><td>{{.Failed}}</td><td>{{.P50}}</td><td>{{.P99}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Errors</h2>
+{{if .Failures}}<table>
+<tr><th>Count</th><th>%</th><th>Message</th></tr>
+{{range .Failures}}<tr><td>{{.Count}}</td><td>{{.Percent}}</td><td class="message">{{.Message}}</td></tr>
+{{end}}</table>
+{{else}}<p>No errors</p>
+{{end}}<h2>Configuration</h2>
+<pre>{{.Settings}}</pre>
+</body>
+</html>
+`))
@@ -0,0 +1,66 @@
+package fperf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestWriteHTML(t *testing.T) {
+	var requests int64
+	registerUnary("test-unary-html", &requests, true)
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-html"
+	conf.Delay = time.Millisecond
+	conf.Duration = 100 * time.Millisecond
+	conf.Tick = 20 * time.Millisecond
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//a failed benchmark has no latencies to chart
+	buf := bytes.NewBuffer(nil)
+	if err := res.WriteHTML(buf); err != nil {
+		t.Fatal(err)
+	}
+	if page := buf.String(); !strings.Contains(page, "request failed") {
+		t.Fatalf("expect the errors in the report, got\n%s", page)
+	}
+
+	registerUnary("test-unary-html-ok", &requests, false)
+	conf.Target = "test-unary-html-ok"
+	res, err = Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := res.WriteHTML(buf); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, s := range []string{
+		"<title>fperf test-unary-html-ok</title>",
+		"Throughput</text>", "Latency</text>", "Latency distribution</text>",
+		"<polyline", "<rect", "<th>p99.9</th>",
+		"No errors",
+		`&#34;target&#34;: &#34;test-unary-html-ok&#34;`,
+	} {
+		if !strings.Contains(page, s) {
+			t.Fatalf("expect %q in the report\n%s", s, page)
+		}
+	}
+}
+
+func TestNiceCeil(t *testing.T) {
+	for _, c := range []struct{ v, expect float64 }{
+		{0, 1}, {0.3, 0.5}, {1, 1}, {1.2, 2}, {3, 5}, {7, 10}, {4200, 5000},
+	} {
+		if got := niceCeil(c.v); got != c.expect {
+			t.Fatalf("expect niceCeil(%v) %v, got %v", c.v, c.expect, got)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package fperf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Tags are the key=value pairs attached to the exported metrics, it can be used as a
+//flag.Value and be set multiple times
+type Tags map[string]string
+
+//Set parses a tag in the form of key=value
+func (t Tags) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 || i == len(s)-1 {
+		return fmt.Errorf("invalid tag %q, should be key=value", s)
+	}
+	t[s[:i]] = s[i+1:]
+	return nil
+}
+
+func (t Tags) String() string {
+	pairs := make([]string, 0, len(t))
+	for k, v := range t {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+//influxQueue is the number of points waiting to be pushed, the ticks exported when
+//it is full are dropped
+const influxQueue = 100
+
+//influxExporter pushes every tick to InfluxDB in line protocol
+type influxExporter struct {
+	measurement string
+	tags        string //escaped and sorted tags with the leading comma
+	send        func(line []byte) error
+	close       func() error //releases the connection
+	lines       chan []byte  //the points waiting to be pushed
+	done        chan struct{}
+}
+
+//NewInfluxDBExporter returns an Exporter writing the ticks to InfluxDB in line protocol.
+//addr is the write endpoint like http://127.0.0.1:8086/write?db=fperf, or a UDP listener
+//like udp://127.0.0.1:8089. The tags are attached to every point of the measurement "fperf".
+//The points are pushed in the background so a slow InfluxDB does not delay the ticks, the
+//exporter implements io.Closer to push the queued points and close the connection
+func NewInfluxDBExporter(addr string, tags Tags) (Exporter, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	e := &influxExporter{
+		measurement: "fperf",
+		lines:       make(chan []byte, influxQueue),
+		done:        make(chan struct{}),
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e.tags += "," + escapeTag(k) + "=" + escapeTag(tags[k])
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		client := &http.Client{Timeout: 5 * time.Second}
+		e.send = func(line []byte) error {
+			resp, err := client.Post(addr, "text/plain; charset=utf-8", bytes.NewReader(line))
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				body, _ := ioutil.ReadAll(resp.Body)
+				return fmt.Errorf("influxdb: %s %s", resp.Status, strings.TrimSpace(string(body)))
+			}
+			return nil
+		}
+		e.close = func() error {
+			client.CloseIdleConnections()
+			return nil
+		}
+	case "udp":
+		conn, err := net.Dial("udp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+		e.send = func(line []byte) error {
+			_, err := conn.Write(line)
+			return err
+		}
+		e.close = conn.Close
+	default:
+		return nil, fmt.Errorf("unsupported influxdb address %q, should be http(s):// or udp://", addr)
+	}
+	go e.push()
+	return e, nil
+}
+
+//Export queues the point of the tick, it is dropped if the queue is full
+func (e *influxExporter) Export(t Tick) error {
+	select {
+	case e.lines <- e.line(t):
+		return nil
+	default:
+		return errors.New("influxdb: too many points waiting to be pushed, drop the tick")
+	}
+}
+
+//push sends the queued points until the exporter is closed, the errors are logged
+func (e *influxExporter) push() {
+	defer close(e.done)
+	for line := range e.lines {
+		if err := e.send(line); err != nil {
+			log.Println("export:", err)
+		}
+	}
+}
+
+//Close pushes the queued points and closes the connection, the exporter can not be used
+//after that
+func (e *influxExporter) Close() error {
+	close(e.lines)
+	<-e.done
+	return e.close()
+}
+
+//line formats the tick as a point of line protocol, latencies are integers in nanoseconds
+func (e *influxExporter) line(t Tick) []byte {
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "%s%s qps=%s,requests=%di,errors=%di,mean=%di,p50=%di,p99=%di,max=%di",
+		e.measurement, e.tags, strconv.FormatFloat(t.QPS, 'f', -1, 64), t.Requests, t.Errors,
+		int64(t.Mean), int64(t.P50), int64(t.P99), int64(t.Max))
+	if t.Corrected > 0 {
+		fmt.Fprintf(buf, ",corrected=%di", int64(t.Corrected))
+	}
+	if t.Timeouts > 0 {
+		fmt.Fprintf(buf, ",timeouts=%di", t.Timeouts)
+	}
+	if t.Sent > 0 || t.Received > 0 {
+		fmt.Fprintf(buf, ",sent=%di,received=%di", t.Sent, t.Received)
+	}
+	if t.Warmup {
+		buf.WriteString(",warmup=true")
+	}
+	fmt.Fprintf(buf, " %d\n", t.Time.UnixNano())
+	return buf.Bytes()
+}
+
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+//escapeTag escapes the keys and values of tags in line protocol
+func escapeTag(s string) string {
+	return tagEscaper.Replace(s)
+}
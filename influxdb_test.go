@@ -0,0 +1,158 @@
+package fperf
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testTick() Tick {
+	return Tick{
+		Time:     time.Unix(1500000000, 0),
+		Requests: 100,
+		Errors:   2,
+		QPS:      49.5,
+		Mean:     2 * time.Millisecond,
+		P50:      time.Millisecond,
+		P99:      10 * time.Millisecond,
+		Max:      20 * time.Millisecond,
+	}
+}
+
+const testLine = `fperf,client=redis,host=my\ box qps=49.5,requests=100i,errors=2i,mean=2000000i,p50=1000000i,p99=10000000i,max=20000000i 1500000000000000000` + "\n"
+
+func TestInfluxDBExporterHTTP(t *testing.T) {
+	lines := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Query().Get("db") != "fperf" {
+			http.Error(w, "database not found", http.StatusNotFound)
+			return
+		}
+		lines <- string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	e, err := NewInfluxDBExporter(server.URL+"/write?db=fperf", Tags{"client": "redis", "host": "my box"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Export(testTick()); err != nil {
+		t.Fatal(err)
+	}
+	if line := <-lines; line != testLine {
+		t.Fatalf("expect %q, got %q", testLine, line)
+	}
+	tick := testTick()
+	tick.Warmup = true
+	if err := e.Export(tick); err != nil {
+		t.Fatal(err)
+	}
+	if line := <-lines; !strings.HasSuffix(line, ",warmup=true 1500000000000000000\n") {
+		t.Fatalf("expect the warm-up field, got %q", line)
+	}
+
+	if err := e.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	e, _ = NewInfluxDBExporter(server.URL+"/write?db=nodb", nil)
+	defer e.(io.Closer).Close()
+	if err := e.(*influxExporter).send([]byte(testLine)); err == nil || !strings.Contains(err.Error(), "database not found") {
+		t.Fatalf("expect error from influxdb, got %v", err)
+	}
+}
+
+func TestInfluxDBExporterQueue(t *testing.T) {
+	unblock := make(chan struct{})
+	var pushed int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		atomic.AddInt64(&pushed, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	e, err := NewInfluxDBExporter(server.URL+"/write?db=fperf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//a blocked influxdb does not block the ticks, the points beyond the queue are dropped
+	start := time.Now()
+	exported := 0
+	for i := 0; i < influxQueue+10; i++ {
+		if err := e.Export(testTick()); err == nil {
+			exported++
+		}
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expect the export not blocked, took %v", d)
+	}
+	if exported < influxQueue || exported > influxQueue+1 {
+		t.Fatalf("expect the points beyond the queue dropped, exported %d", exported)
+	}
+	close(unblock)
+	if err := e.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&pushed); n != int64(exported) {
+		t.Fatalf("expect the queued points pushed by Close, got %d of %d", n, exported)
+	}
+}
+
+func TestInfluxDBExporterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	e, err := NewInfluxDBExporter("udp://"+conn.LocalAddr().String(), Tags{"client": "redis", "host": "my box"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Export(testTick()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := string(buf[:n]); line != testLine {
+		t.Fatalf("expect %q, got %q", testLine, line)
+	}
+
+	if _, err := NewInfluxDBExporter("tcp://127.0.0.1:8086", nil); err == nil {
+		t.Fatal("expect error for unsupported scheme")
+	}
+}
+
+func TestTags(t *testing.T) {
+	tags := make(Tags)
+	if err := tags.Set("env=prod"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tags.Set("dc=a=b"); err != nil {
+		t.Fatal(err)
+	}
+	if tags.String() != "dc=a=b,env=prod" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	for _, s := range []string{"env", "=prod", "env="} {
+		if err := tags.Set(s); err == nil {
+			t.Fatalf("%q: expect error", s)
+		}
+	}
+}
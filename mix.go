@@ -0,0 +1,228 @@
+package fperf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+//MixEntry is a registered client, or an operation of it, sending a share of the
+//requests in proportion to its weight
+type MixEntry struct {
+	Client    string   `json:"client"`
+	Operation string   `json:"operation,omitempty"` //passed to an OperationClient
+	Weight    int      `json:"weight"`
+	Args      []string `json:"args,omitempty"` //parsed by the FlagSet of the client, Config.Args if nil
+}
+
+//name returns the client and operation, the statistics of the entry are named by it
+func (e MixEntry) name() string {
+	if e.Operation == "" {
+		return e.Client
+	}
+	return e.Client + ":" + e.Operation
+}
+
+//Mix dispatches the requests across several clients or operations by weight, it can be
+//used as a flag.Value in the form of "redis:get=70,redis:set=30", or "@mix.json" to read
+//the entries from a JSON file
+type Mix []MixEntry
+
+//Set parses the entries from s or the file named after @
+func (m *Mix) Set(s string) error {
+	var mix Mix
+	if strings.HasPrefix(s, "@") {
+		data, err := ioutil.ReadFile(s[1:])
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &mix); err != nil {
+			return fmt.Errorf("invalid mix file %s: %v", s[1:], err)
+		}
+	} else {
+		for _, field := range strings.Split(s, ",") {
+			i := strings.LastIndex(field, "=")
+			if i < 0 {
+				return fmt.Errorf("invalid mix entry %q, should be client:operation=weight", field)
+			}
+			var e MixEntry
+			var err error
+			if e.Weight, err = strconv.Atoi(strings.TrimSpace(field[i+1:])); err != nil {
+				return fmt.Errorf("invalid weight of mix entry %q", field)
+			}
+			name := strings.TrimSpace(field[:i])
+			if j := strings.Index(name, ":"); j >= 0 {
+				name, e.Operation = name[:j], name[j+1:]
+			}
+			e.Client = name
+			mix = append(mix, e)
+		}
+	}
+	if err := mix.validate(); err != nil {
+		return err
+	}
+	*m = mix
+	return nil
+}
+
+func (m *Mix) String() string {
+	if m == nil {
+		return ""
+	}
+	fields := make([]string, len(*m))
+	for i, e := range *m {
+		fields[i] = e.name() + "=" + strconv.Itoa(e.Weight)
+	}
+	return strings.Join(fields, ",")
+}
+
+//validate checks the weights and that the entries are distinct
+func (m Mix) validate() error {
+	names := make(map[string]bool)
+	for _, e := range m {
+		if e.Client == "" {
+			return errors.New("client of mix entries should not be empty")
+		}
+		if e.Weight <= 0 {
+			return fmt.Errorf("weight of mix entry %s should be greater than 0", e.name())
+		}
+		if names[e.name()] {
+			return fmt.Errorf("duplicated mix entry %s", e.name())
+		}
+		names[e.name()] = true
+	}
+	return nil
+}
+
+//mixTarget is the client of an entry of the mix
+type mixTarget struct {
+	name   string
+	client string //the registered name of cli
+	cli    UnaryClient
+	call   func(ctx context.Context) (Outcome, error)
+	upto   int //cumulative weight
+}
+
+//mixClient is the unary client of a connection in mix mode, it sends every request by
+//an entry picked at random by weight and names the operation of the outcome after it
+type mixClient struct {
+	targets []mixTarget
+	total   int
+}
+
+//dialMix creates and dials the clients of the entries of the mix to addr
+func (r *runner) dialMix(addr string, args []string) (*mixClient, error) {
+	m := &mixClient{}
+	for _, e := range r.conf.Mix {
+		entryArgs := args
+		if e.Args != nil {
+			entryArgs = e.Args
+		}
+		cli, err := r.dial(e.Client, addr, entryArgs)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.total += e.Weight
+		t, err := newMixTarget(e, cli, m.total)
+		if err != nil {
+			closeClient(cli, "client")
+			m.Close()
+			return nil, err
+		}
+		m.targets = append(m.targets, t)
+	}
+	return m, nil
+}
+
+//newMixTarget checks that cli can send the requests of e
+func newMixTarget(e MixEntry, cli Client, upto int) (mixTarget, error) {
+	t := mixTarget{name: e.name(), client: e.Client, upto: upto}
+	unary, ok := cli.(UnaryClient)
+	if !ok {
+		return t, fmt.Errorf("%s does not implement the fperf.UnaryClient", e.Client)
+	}
+	t.cli = unary
+	if e.Operation == "" {
+		t.call = unaryCall(unary)
+		return t, nil
+	}
+	opcli, ok := cli.(OperationClient)
+	if !ok {
+		return t, fmt.Errorf("%s does not implement the fperf.OperationClient for operation %s", e.Client, e.Operation)
+	}
+	operation := e.Operation
+	t.call = func(ctx context.Context) (Outcome, error) {
+		return opcli.RequestOperation(ctx, operation)
+	}
+	return t, nil
+}
+
+//Dial does nothing, the clients of the entries are dialed by dialMix
+func (m *mixClient) Dial(addr string) error {
+	return nil
+}
+
+func (m *mixClient) Request() error {
+	_, err := m.RequestOutcome(context.Background())
+	return err
+}
+
+//RequestOutcome sends a request by an entry picked at random by weight
+func (m *mixClient) RequestOutcome(ctx context.Context) (Outcome, error) {
+	n := rand.Intn(m.total)
+	i := sort.Search(len(m.targets), func(i int) bool { return m.targets[i].upto > n })
+	t := m.targets[i]
+	out, err := t.call(ctx)
+	out.Operation = t.name
+	return out, err
+}
+
+//Setup sets up the clients implementing SetupClient, once for every registered
+//client like the first client of a benchmark
+func (m *mixClient) Setup(ctx context.Context) error {
+	done := make(map[string]bool)
+	for _, t := range m.targets {
+		if cli, ok := t.cli.(SetupClient); ok && !done[t.client] {
+			done[t.client] = true
+			if err := cli.Setup(ctx); err != nil {
+				return fmt.Errorf("%s: %v", t.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+//Teardown tears down the clients implementing TeardownClient once for every registered
+//client, all of them are torn down even if some fail
+func (m *mixClient) Teardown(ctx context.Context) error {
+	var errs []string
+	done := make(map[string]bool)
+	for _, t := range m.targets {
+		if cli, ok := t.cli.(TeardownClient); ok && !done[t.client] {
+			done[t.client] = true
+			if err := cli.Teardown(ctx); err != nil {
+				errs = append(errs, t.name+": "+err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+//Close closes the clients of the entries implementing io.Closer
+func (m *mixClient) Close() error {
+	for _, t := range m.targets {
+		closeClient(t.cli, "client "+t.name)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package fperf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestMixSet(t *testing.T) {
+	var m Mix
+	if err := m.Set("redis:get=70, redis:set=30,http=5"); err != nil {
+		t.Fatal(err)
+	}
+	expect := Mix{{Client: "redis", Operation: "get", Weight: 70}, {Client: "redis", Operation: "set", Weight: 30}, {Client: "http", Weight: 5}}
+	if !reflect.DeepEqual(m, expect) {
+		t.Fatalf("expect %+v, got %+v", expect, m)
+	}
+	if m.String() != "redis:get=70,redis:set=30,http=5" {
+		t.Fatalf("unexpected string %q", m.String())
+	}
+	for _, s := range []string{"redis", "redis:get=x", "redis:get=0", "=10", "redis:get=1,redis:get=2"} {
+		if err := m.Set(s); err == nil {
+			t.Fatalf("expect error for %q", s)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "fperf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "mix.json")
+	data := `[{"client": "redis", "operation": "get", "weight": 70, "args": ["-key", "k"]}]`
+	if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set("@" + name); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 1 || m[0].Operation != "get" || !reflect.DeepEqual(m[0].Args, []string{"-key", "k"}) {
+		t.Fatalf("unexpected mix from file %+v", m)
+	}
+}
+
+//opcli counts the requests of every operation
+type opcli struct {
+	gets, sets *int64
+}
+
+func (c *opcli) Dial(addr string) error {
+	return nil
+}
+
+func (c *opcli) Request() error {
+	return nil
+}
+
+func (c *opcli) RequestOperation(ctx context.Context, operation string) (Outcome, error) {
+	if operation == "get" {
+		atomic.AddInt64(c.gets, 1)
+	} else {
+		atomic.AddInt64(c.sets, 1)
+	}
+	return Outcome{Operation: "ignored"}, nil
+}
+
+func TestRunMix(t *testing.T) {
+	var gets, sets, requests int64
+	Register("test-mix-op", func(flag *FlagSet) Client {
+		return &opcli{gets: &gets, sets: &sets}
+	})
+	registerUnary("test-mix-unary", &requests, false)
+
+	conf := DefaultConfig()
+	conf.Connection = 2
+	conf.N = 1000
+	if err := conf.Mix.Set("test-mix-op:get=60,test-mix-op:set=30,test-mix-unary=10"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Config.Target != conf.Mix.String() || res.Config.CallType != "unary" {
+		t.Fatalf("expect the mix as the target of a unary benchmark, got %q %q", res.Config.Target, res.Config.CallType)
+	}
+	if gets+sets+requests != 2000 || gets < 1000 || gets > 1400 || requests > 400 {
+		t.Fatalf("expect 2000 requests sent by weight, got %d gets %d sets %d requests", gets, sets, requests)
+	}
+	counts := make(map[string]int64)
+	for _, o := range res.Operations {
+		counts[o.Name] = o.Requests
+	}
+	expect := map[string]int64{"test-mix-op:get": gets, "test-mix-op:set": sets, "test-mix-unary": requests}
+	if !reflect.DeepEqual(counts, expect) {
+		t.Fatalf("expect the statistics of every entry %v, got %v", expect, counts)
+	}
+
+	//the entries with an operation need an OperationClient
+	conf.Mix = Mix{{Client: "test-mix-unary", Operation: "get", Weight: 1}}
+	if _, err := Run(context.Background(), conf); err == nil {
+		t.Fatal("expect error for an operation of a client without operations")
+	}
+	conf.Mix = Mix{{Client: "not-registered", Weight: 1}}
+	if _, err := Run(context.Background(), conf); err == nil {
+		t.Fatal("expect error for unregistered client")
+	}
+}
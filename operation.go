@@ -0,0 +1,146 @@
+package fperf
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	hist "github.com/fperf/fperf/stats"
+)
+
+//maxOperations limits the number of distinct operations kept by a recorder, the
+//others are counted in otherOperation
+const maxOperations = 100
+const otherOperation = "other"
+
+//operationSamples is the samples of an operation, latencies are of the successful requests
+type operationSamples struct {
+	latencies []time.Duration
+	requests  int64
+	errors    int64
+}
+
+//add appends the samples of src
+func (s *operationSamples) add(src *operationSamples) {
+	s.latencies = append(s.latencies, src.latencies...)
+	s.requests += src.requests
+	s.errors += src.errors
+}
+
+func (s *operationSamples) reset() {
+	s.latencies = s.latencies[:0]
+	s.requests = 0
+	s.errors = 0
+}
+
+//OperationResult is the statistics of the requests of an operation reported by the outcomes
+type OperationResult struct {
+	Name      string          `json:"name"`
+	Requests  int64           `json:"requests"`
+	Errors    int64           `json:"errors"` //including the failed outcomes
+	Histogram *hist.Histogram `json:"histogram"`
+}
+
+//QPS returns the successful requests per second of the operation during elapsed
+func (o *OperationResult) QPS(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(o.Requests-o.Errors) / elapsed.Seconds()
+}
+
+//sortedOperations returns the names of the operations in order
+func sortedOperations(operations map[string]*operationSamples) []string {
+	names := make([]string, 0, len(operations))
+	for name, samples := range operations {
+		if samples.requests > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+//operationTicks calculates the statistics of every operation of the tick, nil if
+//no outcome has an operation
+func operationTicks(snap *snapshot, now time.Time, interval time.Duration) map[string]Tick {
+	names := sortedOperations(snap.operations)
+	if len(names) == 0 {
+		return nil
+	}
+	ticks := make(map[string]Tick, len(names))
+	for _, name := range names {
+		samples := snap.operations[name]
+		t := newTick(&snapshot{latencies: samples.latencies, requests: samples.requests, errors: samples.errors}, now, interval)
+		//the samples are reused by the next tick
+		t.latencies = nil
+		ticks[name] = t
+	}
+	return ticks
+}
+
+//logOperations logs a line of every operation of the tick
+func logOperations(ticks map[string]Tick) {
+	names := make([]string, 0, len(ticks))
+	for name := range ticks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Printf("  %s %v\n", name, ticks[name])
+	}
+}
+
+//addOperations adds the samples of a tick to the results of the operations
+func (r *runner) addOperations(snap *snapshot) {
+	for name, samples := range snap.operations {
+		if samples.requests == 0 {
+			continue
+		}
+		o := r.operations[name]
+		if o == nil {
+			o = &OperationResult{Name: name, Histogram: hist.NewHistogram(r.conf.Histogram)}
+			r.operations[name] = o
+		}
+		o.Requests += samples.requests
+		o.Errors += samples.errors
+		for _, eplase := range samples.latencies {
+			addLatency(o.Histogram, eplase)
+		}
+	}
+}
+
+//operationResults returns the results of the operations by name
+func (r *runner) operationResults() []*OperationResult {
+	if len(r.operations) == 0 {
+		return nil
+	}
+	results := make([]*OperationResult, 0, len(r.operations))
+	for _, o := range r.operations {
+		results = append(results, o)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+//printOperations writes the throughput and the percentiles of every operation
+func (res *Result) printOperations(w io.Writer) {
+	if len(res.Operations) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%-12s  %10s  %8s  %10s", "operation", "requests", "errors", "qps")
+	for _, q := range percentiles {
+		fmt.Fprintf(w, "  %10s", percentileName(q))
+	}
+	fmt.Fprintf(w, "  %10s  %10s\n", "max", "mean")
+	for _, o := range res.Operations {
+		h := o.Histogram
+		fmt.Fprintf(w, "%-12s  %10d  %8d  %10.1f", o.Name, o.Requests, o.Errors, o.QPS(res.Elapsed))
+		for _, q := range percentiles {
+			fmt.Fprintf(w, "  %10v", roundDuration(time.Duration(h.Percentile(q))))
+		}
+		fmt.Fprintf(w, "  %10v  %10v\n", roundDuration(time.Duration(h.Percentile(100))), roundDuration(time.Duration(h.Mean())))
+	}
+}
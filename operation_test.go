@@ -0,0 +1,67 @@
+package fperf
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestRunOperations(t *testing.T) {
+	Register("test-unary-operation", func(flag *FlagSet) Client {
+		return &outcomecli{}
+	})
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-operation"
+	conf.N = 100
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Operations) != 2 {
+		t.Fatalf("expect GET and SET, got %d operations", len(res.Operations))
+	}
+	get, set := res.Operations[0], res.Operations[1]
+	if get.Name != "GET" || get.Requests != 50 || get.Errors != 25 || get.Histogram.Count != 25 {
+		t.Fatalf("unexpected GET %+v", get)
+	}
+	if set.Name != "SET" || set.Requests != 50 || set.Errors != 0 || set.Histogram.Count != 50 {
+		t.Fatalf("unexpected SET %+v", set)
+	}
+
+	requests := int64(0)
+	for _, tick := range res.Ticks {
+		for _, op := range tick.Operations {
+			requests += op.Requests
+		}
+	}
+	if requests != 100 {
+		t.Fatalf("expect 100 requests in the ticks of the operations, got %d", requests)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	res.Print(buf)
+	if !strings.Contains(buf.String(), "operation") || !strings.Contains(buf.String(), "SET                   50") {
+		t.Fatalf("expect a row of every operation in the report\n%s", buf)
+	}
+}
+
+func TestRecorderOperations(t *testing.T) {
+	rec := newRecorder(false)
+	for i := 0; i < maxOperations+10; i++ {
+		rec.recordOutcome(time.Millisecond, 0, Outcome{Operation: strconv.Itoa(i)}, nil)
+	}
+	rec.recordOutcome(time.Millisecond, 0, Outcome{Operation: "0", Failed: true}, nil)
+	snap := &snapshot{failures: make(map[string]int64)}
+	rec.collect(snap)
+	if len(snap.operations) != maxOperations+1 || snap.operations[otherOperation].requests != 10 {
+		t.Fatalf("expect %d operations and 10 others, got %d", maxOperations, len(snap.operations))
+	}
+	if op := snap.operations["0"]; op.requests != 2 || op.errors != 1 || len(op.latencies) != 1 {
+		t.Fatalf("unexpected samples of operation 0 %+v", op)
+	}
+}
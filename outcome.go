@@ -0,0 +1,126 @@
+package fperf
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	hist "github.com/fperf/fperf/stats"
+)
+
+//Outcome is what an OutcomeClient reports about a request besides its error
+type Outcome struct {
+	Sent      int64  //bytes sent
+	Received  int64  //bytes received
+	Operation string //name of the operation like GET or SET, optional
+	Status    string //status code or label like 200 or MOVED, optional
+	Failed    bool   //an application-level failure like an HTTP 500, counted as an error
+}
+
+//failure returns the message of a failed outcome, grouped with the errors
+func (o Outcome) failure() string {
+	msg := "failed"
+	if o.Status != "" {
+		msg += " with status " + o.Status
+	}
+	if o.Operation != "" {
+		msg = o.Operation + " " + msg
+	}
+	return msg
+}
+
+//outcomeKey groups the latencies by operation and status
+type outcomeKey struct {
+	operation string
+	status    string
+}
+
+//maxOutcomes limits the number of distinct keys kept by a recorder, the statuses of
+//the others are replaced by otherStatus
+const maxOutcomes = 100
+const otherStatus = "other"
+
+//OutcomeResult is the statistics of the requests of an operation and status, the
+//requests failed with an error are excluded
+type OutcomeResult struct {
+	Operation string          `json:"operation,omitempty"`
+	Status    string          `json:"status,omitempty"`
+	Requests  int64           `json:"requests"`
+	Failed    int64           `json:"failed"` //the application-level failures
+	Histogram *hist.Histogram `json:"histogram"`
+}
+
+//name returns the operation and status
+func (o *OutcomeResult) name() string {
+	switch {
+	case o.Operation == "":
+		return o.Status
+	case o.Status == "":
+		return o.Operation
+	}
+	return o.Operation + " " + o.Status
+}
+
+//addOutcomes adds the samples of a tick to the results by operation and status
+func (r *runner) addOutcomes(snap *snapshot) {
+	for key, samples := range snap.outcomes {
+		if len(samples.latencies) == 0 {
+			continue
+		}
+		o := r.outcomes[key]
+		if o == nil {
+			o = &OutcomeResult{Operation: key.operation, Status: key.status, Histogram: hist.NewHistogram(r.conf.Histogram)}
+			r.outcomes[key] = o
+		}
+		o.Requests += int64(len(samples.latencies))
+		o.Failed += samples.failed
+		for _, eplase := range samples.latencies {
+			addLatency(o.Histogram, eplase)
+		}
+	}
+}
+
+//outcomeResults returns the results by operation and status in order
+func (r *runner) outcomeResults() []*OutcomeResult {
+	if len(r.outcomes) == 0 {
+		return nil
+	}
+	results := make([]*OutcomeResult, 0, len(r.outcomes))
+	for _, o := range r.outcomes {
+		results = append(results, o)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Operation != results[j].Operation {
+			return results[i].Operation < results[j].Operation
+		}
+		return results[i].Status < results[j].Status
+	})
+	return results
+}
+
+//megabytes returns the rate of n bytes in MB/s
+func megabytes(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / 1e6 / d.Seconds()
+}
+
+//printOutcomes writes the transfer rates and the latencies by operation and status
+func (res *Result) printOutcomes(w io.Writer) {
+	if res.Sent > 0 || res.Received > 0 {
+		fmt.Fprintf(w, "Sent: %d bytes (%.2f MB/s)  Received: %d bytes (%.2f MB/s)\n", res.Sent,
+			megabytes(res.Sent, res.Elapsed), res.Received, megabytes(res.Received, res.Elapsed))
+	}
+	if len(res.Outcomes) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%-20s  %10s  %8s  %10s  %10s  %10s\n", "status", "requests", "failed", "p50", "p99", "max")
+	for _, o := range res.Outcomes {
+		h := o.Histogram
+		fmt.Fprintf(w, "%-20s  %10d  %8d  %10v  %10v  %10v\n", o.name(), o.Requests, o.Failed,
+			roundDuration(time.Duration(h.Percentile(50))), roundDuration(time.Duration(h.Percentile(99))),
+			roundDuration(time.Duration(h.Percentile(100))))
+	}
+}
@@ -0,0 +1,94 @@
+package fperf
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+//outcomecli sets and gets in turn, every fourth get fails with status 500
+type outcomecli struct {
+	requests int64
+}
+
+func (c *outcomecli) Dial(addr string) error {
+	return nil
+}
+
+func (c *outcomecli) Request() error {
+	_, err := c.RequestOutcome(context.Background())
+	return err
+}
+
+func (c *outcomecli) RequestOutcome(ctx context.Context) (Outcome, error) {
+	n := atomic.AddInt64(&c.requests, 1)
+	if n%2 == 1 {
+		return Outcome{Sent: 100, Received: 10, Operation: "SET", Status: "200"}, nil
+	}
+	if n%4 == 0 {
+		return Outcome{Sent: 10, Received: 10, Operation: "GET", Status: "500", Failed: true}, nil
+	}
+	return Outcome{Sent: 10, Received: 100, Operation: "GET", Status: "200"}, nil
+}
+
+func TestRunOutcomes(t *testing.T) {
+	Register("test-unary-outcome", func(flag *FlagSet) Client {
+		return &outcomecli{}
+	})
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-outcome"
+	conf.N = 100
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 50*100+50*10 || res.Received != 50*10+25*100+25*10 {
+		t.Fatalf("unexpected bytes sent %d received %d", res.Sent, res.Received)
+	}
+	if res.Errors != 25 || res.Failures["GET failed with status 500"] != 25 {
+		t.Fatalf("expect 25 application failures, got %d %v", res.Errors, res.Failures)
+	}
+	if res.Histogram.Count != 75 {
+		t.Fatalf("expect the failures excluded from the histogram, got %d", res.Histogram.Count)
+	}
+
+	var names []string
+	for _, o := range res.Outcomes {
+		names = append(names, o.name()+"="+strconv.FormatInt(o.Requests, 10)+"/"+strconv.FormatInt(o.Failed, 10))
+	}
+	if expect := "GET 200=25/0 GET 500=25/25 SET 200=50/0"; strings.Join(names, " ") != expect {
+		t.Fatalf("expect outcomes %q, got %q", expect, strings.Join(names, " "))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	res.Print(buf)
+	for _, s := range []string{"Received: 3250 bytes", "GET 500", "SET 200"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Fatalf("expect %q in the report\n%s", s, buf)
+		}
+	}
+}
+
+func TestRecorderOutcomes(t *testing.T) {
+	rec := newRecorder(false)
+	for i := 0; i < maxOutcomes+10; i++ {
+		rec.recordOutcome(time.Millisecond, 0, Outcome{Status: strconv.Itoa(i)}, nil)
+	}
+	snap := &snapshot{failures: make(map[string]int64)}
+	rec.collect(snap)
+	if len(snap.outcomes) != maxOutcomes+1 || len(snap.outcomes[outcomeKey{status: otherStatus}].latencies) != 10 {
+		t.Fatalf("expect %d statuses and 10 others, got %d", maxOutcomes, len(snap.outcomes))
+	}
+
+	snap.reset()
+	rec.collect(snap)
+	if len(snap.outcomes[outcomeKey{status: "0"}].latencies) != 0 {
+		t.Fatal("expect recorder reset after collect")
+	}
+}
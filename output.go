@@ -0,0 +1,81 @@
+package fperf
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	hist "github.com/fperf/fperf/stats"
+)
+
+//jsonResult is the JSON document of a Result, durations are in nanoseconds
+type jsonResult struct {
+	*Result
+	QPS              float64            `json:"qps"` //successful requests per second
+	Summary          map[string]float64 `json:"summary"`
+	CorrectedSummary map[string]float64 `json:"corrected_summary,omitempty"`
+}
+
+//WriteJSON writes the result as a JSON document with the settings, the statistics of
+//every tick, the percentile summary and the histogram buckets
+func (res *Result) WriteJSON(w io.Writer) error {
+	doc := jsonResult{
+		Result:  res,
+		QPS:     res.QPS(),
+		Summary: summarize(res.Histogram),
+	}
+	if res.Corrected != nil {
+		doc.CorrectedSummary = summarize(res.Corrected)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+//ReadJSON reads a result written by WriteJSON
+func ReadJSON(r io.Reader) (*Result, error) {
+	res := &Result{}
+	if err := json.NewDecoder(r).Decode(res); err != nil {
+		return nil, err
+	}
+	if res.Histogram == nil {
+		return nil, errors.New("no histogram in the result")
+	}
+	return res, nil
+}
+
+//QPS returns the successful requests per second of the whole benchmark
+func (res *Result) QPS() float64 {
+	if res.Elapsed <= 0 {
+		return 0
+	}
+	return float64(res.Requests-res.Errors) / res.Elapsed.Seconds()
+}
+
+//summarize returns the percentiles, min, max, mean and stddev of h
+func summarize(h *hist.Histogram) map[string]float64 {
+	m := map[string]float64{
+		"min":    float64(h.Percentile(0)),
+		"max":    float64(h.Percentile(100)),
+		"mean":   h.Mean(),
+		"stddev": h.StdDev(),
+	}
+	for _, q := range percentiles {
+		m[percentileName(q)] = float64(h.Percentile(q))
+	}
+	return m
+}
+
+//outputFormats are the formats of the final report
+var outputFormats = map[string]func(w io.Writer, res *Result) error{
+	"text": func(w io.Writer, res *Result) error {
+		res.Print(w)
+		return nil
+	},
+	"json": func(w io.Writer, res *Result) error {
+		return res.WriteJSON(w)
+	},
+	"html": func(w io.Writer, res *Result) error {
+		return res.WriteHTML(w)
+	},
+}
@@ -0,0 +1,55 @@
+package fperf
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestWriteJSON(t *testing.T) {
+	var requests int64
+	registerUnary("test-unary-json", &requests, false)
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-json"
+	conf.Delay = time.Millisecond
+	conf.Duration = 100 * time.Millisecond
+	conf.Tick = 20 * time.Millisecond
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := res.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Result
+		QPS     float64            `json:"qps"`
+		Summary map[string]float64 `json:"summary"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Config.Target != "test-unary-json" || doc.Config.Tick != conf.Tick {
+		t.Fatalf("unexpected settings %+v", doc.Config)
+	}
+	if doc.Requests != res.Requests || len(doc.Ticks) != len(res.Ticks) || len(doc.Ticks) < 4 {
+		t.Fatalf("expect %d requests in %d ticks, got %d in %d", res.Requests, len(res.Ticks), doc.Requests, len(doc.Ticks))
+	}
+	if doc.Histogram.Count != res.Histogram.Count || doc.Histogram.Percentile(99) != res.Histogram.Percentile(99) {
+		t.Fatal("expect the histogram decoded from its buckets")
+	}
+	for _, key := range []string{"min", "p50", "p90", "p99", "p99.9", "max", "mean", "stddev"} {
+		if _, ok := doc.Summary[key]; !ok {
+			t.Fatalf("expect %s in summary %v", key, doc.Summary)
+		}
+	}
+	if doc.QPS <= 0 {
+		t.Fatalf("expect qps, got %v", doc.QPS)
+	}
+}
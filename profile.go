@@ -0,0 +1,76 @@
+package fperf
+
+import (
+	"log"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+//profiling contains the options of the debug server and the runtime profiles
+type profiling struct {
+	debugAddr     string //empty disables the debug server
+	blockRate     int
+	mutexFraction int
+	cpuFile       string
+	memFile       string
+
+	cpu      *os.File
+	listener net.Listener
+}
+
+//start enables the runtime profiles and serves the http.DefaultServeMux on the debug address
+func (p *profiling) start() error {
+	runtime.SetBlockProfileRate(p.blockRate)
+	runtime.SetMutexProfileFraction(p.mutexFraction)
+	if p.cpuFile != "" {
+		f, err := os.Create(p.cpuFile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		p.cpu = f
+	}
+	if p.debugAddr != "" {
+		l, err := net.Listen("tcp", p.debugAddr)
+		if err != nil {
+			//the benchmark goes on without the debug server
+			log.Println("debug server disabled:", err)
+			return nil
+		}
+		p.listener = l
+		log.Println("debug server listening on", l.Addr())
+		go func() { log.Println(http.Serve(l, nil)) }()
+	}
+	return nil
+}
+
+//stop writes the CPU and heap profiles to their files, the debug server keeps running
+func (p *profiling) stop() error {
+	if p.cpu != nil {
+		pprof.StopCPUProfile()
+		if err := p.cpu.Close(); err != nil {
+			return err
+		}
+		p.cpu = nil
+	}
+	if p.memFile != "" {
+		f, err := os.Create(p.memFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		//the heap profile reports the statistics as of the last GC
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
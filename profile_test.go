@@ -0,0 +1,56 @@
+package fperf
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fperf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &profiling{
+		debugAddr: "127.0.0.1:0",
+		cpuFile:   filepath.Join(dir, "cpu.out"),
+		memFile:   filepath.Join(dir, "mem.out"),
+	}
+	if err := p.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.listener.Close()
+	resp, err := http.Get("http://" + p.listener.Addr().String() + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expect pprof on the debug server, got %s", resp.Status)
+	}
+	if err := p.stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{p.cpuFile, p.memFile} {
+		if info, err := os.Stat(name); err != nil || info.Size() == 0 {
+			t.Fatalf("expect profile written to %s, got %v", name, err)
+		}
+	}
+}
+
+func TestProfilingDisabled(t *testing.T) {
+	p := &profiling{}
+	if err := p.start(); err != nil {
+		t.Fatal(err)
+	}
+	if p.listener != nil {
+		t.Fatal("expect no debug server with an empty address")
+	}
+	if err := p.stop(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,140 @@
+package fperf
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//prometheusBuckets are the upper bounds of the latency histogram
+var prometheusBuckets = []time.Duration{
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+//PrometheusExporter accumulates the ticks and serves them as an http.Handler
+//in Prometheus text exposition format
+type PrometheusExporter struct {
+	mu       sync.Mutex
+	tags     Tags
+	labels   string //escaped labels without braces, empty before Start
+	requests int64
+	errors   int64
+	timeouts int64
+	sent     int64
+	received int64
+	inflight int64
+	buckets  []int64 //cumulative count of every bound of prometheusBuckets
+	count    int64
+	sum      time.Duration
+}
+
+//NewPrometheusExporter returns an exporter labelling the metrics with the target and
+//call type of the benchmark, and the tags
+func NewPrometheusExporter(tags Tags) *PrometheusExporter {
+	return &PrometheusExporter{tags: tags, buckets: make([]int64, len(prometheusBuckets))}
+}
+
+//Start sets the labels from conf
+func (e *PrometheusExporter) Start(conf Config) error {
+	labels := Tags{}
+	for k, v := range e.tags {
+		labels[k] = v
+	}
+	labels["target"] = conf.Target
+	labels["call_type"] = conf.CallType
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = labelName(k) + "=\"" + labelEscaper.Replace(labels[k]) + "\""
+	}
+
+	e.mu.Lock()
+	e.labels = strings.Join(pairs, ",")
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *PrometheusExporter) Export(t Tick) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.inflight = t.InFlight
+	//like the result, the metrics start after the warm-up
+	if t.Warmup {
+		return nil
+	}
+	e.requests += t.Requests
+	e.errors += t.Errors
+	e.timeouts += t.Timeouts
+	e.sent += t.Sent
+	e.received += t.Received
+	for i, bound := range prometheusBuckets {
+		e.buckets[i] += int64(sort.Search(len(t.latencies), func(j int) bool { return t.latencies[j] > bound }))
+	}
+	for _, eplase := range t.latencies {
+		e.sum += eplase
+	}
+	e.count += int64(len(t.latencies))
+	return nil
+}
+
+func (e *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	e.mu.Lock()
+	//nothing to expose until the benchmark is started
+	if e.labels != "" {
+		metric(&buf, "fperf_requests_total", "counter", "Number of requests sent.")
+		fmt.Fprintf(&buf, "fperf_requests_total{%s} %d\n", e.labels, e.requests)
+		metric(&buf, "fperf_errors_total", "counter", "Number of failed requests.")
+		fmt.Fprintf(&buf, "fperf_errors_total{%s} %d\n", e.labels, e.errors)
+		metric(&buf, "fperf_timeouts_total", "counter", "Number of failed requests which timed out.")
+		fmt.Fprintf(&buf, "fperf_timeouts_total{%s} %d\n", e.labels, e.timeouts)
+		metric(&buf, "fperf_sent_bytes_total", "counter", "Number of bytes sent, reported by the clients.")
+		fmt.Fprintf(&buf, "fperf_sent_bytes_total{%s} %d\n", e.labels, e.sent)
+		metric(&buf, "fperf_received_bytes_total", "counter", "Number of bytes received, reported by the clients.")
+		fmt.Fprintf(&buf, "fperf_received_bytes_total{%s} %d\n", e.labels, e.received)
+		metric(&buf, "fperf_in_flight_requests", "gauge", "Number of requests waiting for a response.")
+		fmt.Fprintf(&buf, "fperf_in_flight_requests{%s} %d\n", e.labels, e.inflight)
+		metric(&buf, "fperf_request_duration_seconds", "histogram", "Latency of the successful requests.")
+		for i, bound := range prometheusBuckets {
+			fmt.Fprintf(&buf, "fperf_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				e.labels, strconv.FormatFloat(bound.Seconds(), 'g', -1, 64), e.buckets[i])
+		}
+		fmt.Fprintf(&buf, "fperf_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", e.labels, e.count)
+		fmt.Fprintf(&buf, "fperf_request_duration_seconds_sum{%s} %s\n", e.labels,
+			strconv.FormatFloat(e.sum.Seconds(), 'g', -1, 64))
+		fmt.Fprintf(&buf, "fperf_request_duration_seconds_count{%s} %d\n", e.labels, e.count)
+	}
+	e.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+//metric writes the HELP and TYPE lines of a metric
+func metric(buf *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+//labelName replaces the characters not allowed in a label name with underscores
+func labelName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,85 @@
+package fperf
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func scrape(e *PrometheusExporter) string {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	return w.Body.String()
+}
+
+func TestPrometheusExporter(t *testing.T) {
+	var requests int64
+	registerUnary("test-unary-prometheus", &requests, false)
+
+	e := NewPrometheusExporter(Tags{"host": "my box"})
+	if body := scrape(e); body != "" {
+		t.Fatalf("expect no metrics before the benchmark, got %q", body)
+	}
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-prometheus"
+	conf.N = 100
+	conf.Tick = 10 * time.Millisecond
+	conf.Exporters = []Exporter{e}
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Config.CallType != "unary" {
+		t.Fatalf("expect call type resolved to unary, got %q", res.Config.CallType)
+	}
+
+	body := scrape(e)
+	labels := `call_type="unary",host="my box",target="test-unary-prometheus"`
+	for _, line := range []string{
+		"# TYPE fperf_requests_total counter",
+		"fperf_requests_total{" + labels + "} 100",
+		"fperf_errors_total{" + labels + "} 0",
+		"fperf_in_flight_requests{" + labels + "} 0",
+		"# TYPE fperf_request_duration_seconds histogram",
+		"fperf_request_duration_seconds_bucket{" + labels + `,le="10"} 100`,
+		"fperf_request_duration_seconds_bucket{" + labels + `,le="+Inf"} 100`,
+		"fperf_request_duration_seconds_count{" + labels + "} 100",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("expect %q in\n%s", line, body)
+		}
+	}
+}
+
+func TestPrometheusExporterBuckets(t *testing.T) {
+	e := NewPrometheusExporter(nil)
+	e.Start(Config{Target: `a"b`, CallType: "stream"})
+	tick := testTick()
+	tick.InFlight = 3
+	tick.latencies = []time.Duration{50 * time.Microsecond, time.Millisecond, 3 * time.Millisecond, time.Minute}
+	e.Export(tick)
+	e.Export(tick)
+
+	body := scrape(e)
+	labels := `call_type="stream",target="a\"b"`
+	for _, line := range []string{
+		"fperf_requests_total{" + labels + "} 200",
+		"fperf_errors_total{" + labels + "} 4",
+		"fperf_in_flight_requests{" + labels + "} 3",
+		"fperf_request_duration_seconds_bucket{" + labels + `,le="0.0001"} 2`,
+		"fperf_request_duration_seconds_bucket{" + labels + `,le="0.001"} 4`,
+		"fperf_request_duration_seconds_bucket{" + labels + `,le="0.0025"} 4`,
+		"fperf_request_duration_seconds_bucket{" + labels + `,le="0.005"} 6`,
+		"fperf_request_duration_seconds_bucket{" + labels + `,le="10"} 6`,
+		"fperf_request_duration_seconds_bucket{" + labels + `,le="+Inf"} 8`,
+		"fperf_request_duration_seconds_sum{" + labels + "} 120.0081\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatalf("expect %q in\n%s", line, body)
+		}
+	}
+}
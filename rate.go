@@ -0,0 +1,107 @@
+package fperf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Rate is the number of requests per second, it can be used as a flag.Value
+//in the form of "5000", "5000/s", "300/m" or "5/ms"
+type Rate float64
+
+//Set parses the rate from s
+func (r *Rate) Set(s string) error {
+	count, per := s, "s"
+	if i := strings.Index(s, "/"); i >= 0 {
+		count, per = s[:i], s[i+1:]
+	}
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid rate %q", s)
+	}
+	unit, err := time.ParseDuration("1" + per)
+	if err != nil {
+		return fmt.Errorf("invalid rate unit %q", per)
+	}
+	*r = Rate(n * float64(time.Second) / float64(unit))
+	return nil
+}
+
+func (r *Rate) String() string {
+	return strconv.FormatFloat(float64(*r), 'f', -1, 64) + "/s"
+}
+
+//Interval returns the time between two requests
+func (r Rate) Interval() time.Duration {
+	if r <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / float64(r))
+}
+
+//pacer schedules the requests at a constant rate independent of how slow
+//the responses are. Every worker takes the next slot from the pacer and waits
+//until its time comes, a worker falling behind the schedule fires immediately
+type pacer struct {
+	mu       sync.Mutex
+	interval time.Duration //0 pauses the requests
+	next     time.Time
+}
+
+//pacerPoll is how often the workers check a paused pacer, the workers only take
+//the slots due within it so that a change of the rate applies to the next slots
+const pacerPoll = 10 * time.Millisecond
+
+func newPacer(rate Rate) *pacer {
+	return &pacer{interval: rate.Interval()}
+}
+
+//start schedules the first request at now
+func (p *pacer) start() {
+	p.mu.Lock()
+	p.next = time.Now()
+	p.mu.Unlock()
+}
+
+//set changes the rate from the next slot on, a zero rate pauses the requests
+func (p *pacer) set(rate Rate) {
+	p.mu.Lock()
+	interval := rate.Interval()
+	if p.interval == 0 {
+		//no backlog of the pause
+		p.next = time.Now()
+	} else {
+		p.next = p.next.Add(interval - p.interval)
+	}
+	p.interval = interval
+	p.mu.Unlock()
+}
+
+//take returns the intended send time of the next request, it returns false
+//if the pacer is paused or the next slot is not due within pacerPoll
+func (p *pacer) take() (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.interval == 0 || time.Until(p.next) > pacerPoll {
+		return time.Time{}, false
+	}
+	t := p.next
+	p.next = t.Add(p.interval)
+	return t, true
+}
+
+//wait takes the next slot and sleeps until its time, it returns false
+//if done is closed before that
+func (p *pacer) wait(done <-chan struct{}) (time.Time, bool) {
+	for {
+		if t, ok := p.take(); ok {
+			return t, sleep(done, time.Until(t))
+		}
+		if !sleep(done, pacerPoll) {
+			return time.Time{}, false
+		}
+	}
+}
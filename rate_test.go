@@ -0,0 +1,54 @@
+package fperf
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestRateSet(t *testing.T) {
+	cases := map[string]Rate{
+		"5000":   5000,
+		"5000/s": 5000,
+		"300/m":  5,
+		"2/ms":   2000,
+		"0.5/s":  0.5,
+	}
+	for s, expect := range cases {
+		var r Rate
+		if err := r.Set(s); err != nil {
+			t.Fatal(err)
+		}
+		if r != expect {
+			t.Fatalf("%s: expect %v, got %v", s, expect, r)
+		}
+	}
+	for _, s := range []string{"", "abc", "-1", "10/x", "10/"} {
+		var r Rate
+		if err := r.Set(s); err == nil {
+			t.Fatalf("%q: expect error", s)
+		}
+	}
+	if interval := Rate(4).Interval(); interval != 250*time.Millisecond {
+		t.Fatalf("expect 250ms interval, got %v", interval)
+	}
+}
+
+func TestRunRate(t *testing.T) {
+	var requests int64
+	registerUnary("test-unary-rate", &requests, false)
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-rate"
+	conf.Goroutine = 4
+	conf.Rate = 1000
+	conf.Duration = 200 * time.Millisecond
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests < 150 || res.Requests > 250 {
+		t.Fatalf("expect about 200 requests at 1000/s in 200ms, got %d", res.Requests)
+	}
+}
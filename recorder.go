@@ -0,0 +1,291 @@
+package fperf
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//recorder collects the samples of a single worker. Every worker owns its recorder,
+//so the lock is only contended by the collector when it swaps the samples out at each tick
+type recorder struct {
+	inflight  int64 //requests waiting for a response, accessed atomically
+	mu        sync.Mutex
+	latencies []time.Duration
+	corrected []time.Duration //nil if the correction is disabled
+	requests  int64
+	errors    int64
+	timeouts  int64            //the errors which are timeouts
+	failures  map[string]int64 //number of errors grouped by message
+	sent      int64            //bytes reported by the outcomes
+	received  int64
+	outcomes  map[outcomeKey]*outcomeSamples //nil until an outcome has a status
+	ops       map[string]*operationSamples   //nil until an outcome has an operation
+	warmup    *warmup                        //counts the requests of the warm-up, nil if none
+	warm      *recorder                      //the samples of the warm-up, nil if none
+}
+
+//outcomeSamples is the latencies of an operation and status
+type outcomeSamples struct {
+	latencies []time.Duration
+	failed    int64
+}
+
+//snapshot is the samples swapped out of recorders
+type snapshot struct {
+	latencies  []time.Duration
+	corrected  []time.Duration
+	requests   int64
+	errors     int64
+	timeouts   int64
+	inflight   int64
+	failures   map[string]int64
+	sent       int64
+	received   int64
+	outcomes   map[outcomeKey]*outcomeSamples
+	operations map[string]*operationSamples
+}
+
+//maxFailures limits the number of distinct error messages kept by a recorder,
+//the others are counted in otherFailures
+const maxFailures = 100
+const otherFailures = "other errors"
+
+func newRecorder(correct bool) *recorder {
+	rec := &recorder{
+		latencies: make([]time.Duration, 0, 1024),
+		failures:  make(map[string]int64),
+	}
+	if correct {
+		rec.corrected = make([]time.Duration, 0, 1024)
+	}
+	return rec
+}
+
+//begin marks a request as sent, it is paired with end once the response arrives
+func (rec *recorder) begin() {
+	atomic.AddInt64(&rec.inflight, 1)
+}
+
+func (rec *recorder) end() {
+	atomic.AddInt64(&rec.inflight, -1)
+}
+
+//record adds a sample of a request, eplase is measured from the actual send time and
+//corrected from the intended send time. A failed request is counted by err and its
+//latency is excluded from the samples
+func (rec *recorder) record(eplase, corrected time.Duration, err error) {
+	rec.recordOutcome(eplase, corrected, Outcome{}, err)
+}
+
+//recordOutcome is record with the outcome of the request, a failed outcome is counted
+//as an error. The latency is also kept by operation, and by status unless err is set
+func (rec *recorder) recordOutcome(eplase, corrected time.Duration, out Outcome, err error) {
+	if rec.warmup != nil && rec.warmup.take() {
+		rec.warm.recordOutcome(eplase, corrected, out, err)
+		return
+	}
+	rec.mu.Lock()
+	rec.requests++
+	rec.sent += out.Sent
+	rec.received += out.Received
+	switch {
+	case err != nil:
+		rec.failed(err)
+	case out.Failed:
+		rec.failedMessage(out.failure())
+	default:
+		rec.latencies = append(rec.latencies, eplase)
+		if rec.corrected != nil {
+			rec.corrected = append(rec.corrected, corrected)
+		}
+	}
+	if err == nil && out.Status != "" {
+		rec.addOutcome(out, eplase)
+	}
+	if out.Operation != "" {
+		rec.addOperation(out.Operation, eplase, err != nil || out.Failed)
+	}
+	rec.mu.Unlock()
+}
+
+//fail counts an error which does not belong to a request
+func (rec *recorder) fail(err error) {
+	if rec.warmup != nil && rec.warmup.warming() {
+		rec.warm.fail(err)
+		return
+	}
+	rec.mu.Lock()
+	rec.failed(err)
+	rec.mu.Unlock()
+}
+
+//failed counts err by its message, rec.mu must be held
+func (rec *recorder) failed(err error) {
+	if isTimeout(err) {
+		rec.timeouts++
+	}
+	rec.failedMessage(err.Error())
+}
+
+//failedMessage counts an error of msg, rec.mu must be held
+func (rec *recorder) failedMessage(msg string) {
+	rec.errors++
+	mergeFailure(rec.failures, msg, 1)
+}
+
+//addOutcome keeps the latency by the operation and status of out, rec.mu must be held
+func (rec *recorder) addOutcome(out Outcome, eplase time.Duration) {
+	if rec.outcomes == nil {
+		rec.outcomes = make(map[outcomeKey]*outcomeSamples)
+	}
+	key := outcomeKey{operation: out.Operation, status: out.Status}
+	samples := rec.outcomes[key]
+	if samples == nil && len(rec.outcomes) >= maxOutcomes {
+		key.status = otherStatus
+		samples = rec.outcomes[key]
+	}
+	if samples == nil {
+		samples = &outcomeSamples{}
+		rec.outcomes[key] = samples
+	}
+	samples.latencies = append(samples.latencies, eplase)
+	if out.Failed {
+		samples.failed++
+	}
+}
+
+//mergeFailure adds n errors of msg to failures, keeping at most maxFailures messages
+//besides otherFailures
+func mergeFailure(failures map[string]int64, msg string, n int64) {
+	if _, ok := failures[msg]; !ok && msg != otherFailures {
+		size := len(failures)
+		if _, ok := failures[otherFailures]; ok {
+			size--
+		}
+		if size >= maxFailures {
+			msg = otherFailures
+		}
+	}
+	failures[msg] += n
+}
+
+//mergeFailures adds the errors of src to dst
+func mergeFailures(dst, src map[string]int64) {
+	for msg, n := range src {
+		mergeFailure(dst, msg, n)
+	}
+}
+
+//addOperation counts a request of the operation, rec.mu must be held
+func (rec *recorder) addOperation(name string, eplase time.Duration, failed bool) {
+	if rec.ops == nil {
+		rec.ops = make(map[string]*operationSamples)
+	}
+	samples := rec.ops[name]
+	if samples == nil && len(rec.ops) >= maxOperations {
+		name = otherOperation
+		samples = rec.ops[name]
+	}
+	if samples == nil {
+		samples = &operationSamples{}
+		rec.ops[name] = samples
+	}
+	samples.requests++
+	if failed {
+		samples.errors++
+	} else {
+		samples.latencies = append(samples.latencies, eplase)
+	}
+}
+
+//collect appends the samples to snap and resets the recorder
+func (rec *recorder) collect(snap *snapshot) {
+	rec.mu.Lock()
+	snap.latencies = append(snap.latencies, rec.latencies...)
+	snap.corrected = append(snap.corrected, rec.corrected...)
+	snap.requests += rec.requests
+	snap.errors += rec.errors
+	snap.timeouts += rec.timeouts
+	snap.inflight += atomic.LoadInt64(&rec.inflight)
+	snap.sent += rec.sent
+	snap.received += rec.received
+	mergeFailures(snap.failures, rec.failures)
+	for msg := range rec.failures {
+		delete(rec.failures, msg)
+	}
+	rec.latencies = rec.latencies[:0]
+	if rec.corrected != nil {
+		rec.corrected = rec.corrected[:0]
+	}
+	for key, samples := range rec.outcomes {
+		if len(samples.latencies) == 0 {
+			continue
+		}
+		snap.addOutcome(key, samples)
+		samples.latencies = samples.latencies[:0]
+		samples.failed = 0
+	}
+	for name, samples := range rec.ops {
+		if samples.requests == 0 {
+			continue
+		}
+		snap.addOperation(name, samples)
+		samples.reset()
+	}
+	rec.requests = 0
+	rec.errors = 0
+	rec.timeouts = 0
+	rec.sent = 0
+	rec.received = 0
+	rec.mu.Unlock()
+}
+
+//reset clears the snapshot and keeps its buffer
+func (snap *snapshot) reset() {
+	snap.latencies = snap.latencies[:0]
+	snap.corrected = snap.corrected[:0]
+	snap.requests = 0
+	snap.errors = 0
+	snap.timeouts = 0
+	snap.inflight = 0
+	snap.sent = 0
+	snap.received = 0
+	for msg := range snap.failures {
+		delete(snap.failures, msg)
+	}
+	for _, samples := range snap.outcomes {
+		samples.latencies = samples.latencies[:0]
+		samples.failed = 0
+	}
+	for _, samples := range snap.operations {
+		samples.reset()
+	}
+}
+
+//addOperation appends the samples of an operation
+func (snap *snapshot) addOperation(name string, samples *operationSamples) {
+	if snap.operations == nil {
+		snap.operations = make(map[string]*operationSamples)
+	}
+	s := snap.operations[name]
+	if s == nil {
+		s = &operationSamples{}
+		snap.operations[name] = s
+	}
+	s.add(samples)
+}
+
+//addOutcome appends the samples of an operation and status
+func (snap *snapshot) addOutcome(key outcomeKey, samples *outcomeSamples) {
+	if snap.outcomes == nil {
+		snap.outcomes = make(map[outcomeKey]*outcomeSamples)
+	}
+	s := snap.outcomes[key]
+	if s == nil {
+		s = &outcomeSamples{}
+		snap.outcomes[key] = s
+	}
+	s.latencies = append(s.latencies, samples.latencies...)
+	s.failed += samples.failed
+}
@@ -0,0 +1,35 @@
+package fperf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRecorderFailures(t *testing.T) {
+	rec := newRecorder(false)
+	for i := 0; i < maxFailures+10; i++ {
+		rec.record(time.Millisecond, 0, fmt.Errorf("error %d", i))
+	}
+	rec.record(time.Millisecond, 0, nil)
+	rec.fail(errors.New("error 0"))
+
+	snap := &snapshot{failures: make(map[string]int64)}
+	rec.collect(snap)
+	if snap.requests != maxFailures+11 || snap.errors != maxFailures+11 {
+		t.Fatalf("expect %d requests and errors, got %d %d", maxFailures+11, snap.requests, snap.errors)
+	}
+	if len(snap.latencies) != 1 {
+		t.Fatalf("expect only the successful latency, got %d", len(snap.latencies))
+	}
+	if snap.failures["error 0"] != 2 || snap.failures[otherFailures] != 10 {
+		t.Fatalf("unexpected failures %v", snap.failures)
+	}
+
+	snap.reset()
+	rec.collect(snap)
+	if snap.requests != 0 || len(snap.failures) != 0 {
+		t.Fatal("expect recorder reset after collect")
+	}
+}
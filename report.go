@@ -0,0 +1,146 @@
+package fperf
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	hist "github.com/fperf/fperf/stats"
+)
+
+//percentiles are reported in the summary of a benchmark
+var percentiles = []float64{50, 90, 99, 99.9}
+
+//Print writes the final report of the benchmark
+func (res *Result) Print(w io.Writer) {
+	res.Histogram.Print(w)
+	if res.Corrected != nil {
+		fmt.Fprintln(w, "Corrected for coordinated omission:")
+		res.Corrected.Print(w)
+	}
+	res.printSummary(w)
+	res.printStages(w)
+	res.printOperations(w)
+	res.printOutcomes(w)
+	res.printErrors(w)
+}
+
+//summaryRow is a row of the percentile table, with a value of every histogram
+type summaryRow struct {
+	name   string
+	values []time.Duration
+}
+
+//summary returns the columns and rows of the percentile table, the corrected latencies
+//are in a second column if any
+func (res *Result) summary() ([]string, []summaryRow) {
+	columns := []string{"latency"}
+	hs := []*hist.Histogram{res.Histogram}
+	if res.Corrected != nil {
+		columns = append(columns, "corrected")
+		hs = append(hs, res.Corrected)
+	}
+
+	var rows []summaryRow
+	row := func(name string, value func(h *hist.Histogram) float64) {
+		r := summaryRow{name: name}
+		for _, h := range hs {
+			r.values = append(r.values, roundDuration(time.Duration(value(h))))
+		}
+		rows = append(rows, r)
+	}
+	row("min", func(h *hist.Histogram) float64 { return float64(h.Percentile(0)) })
+	for _, q := range percentiles {
+		q := q
+		row(percentileName(q), func(h *hist.Histogram) float64 { return float64(h.Percentile(q)) })
+	}
+	row("max", func(h *hist.Histogram) float64 { return float64(h.Percentile(100)) })
+	row("mean", func(h *hist.Histogram) float64 { return h.Mean() })
+	row("stddev", func(h *hist.Histogram) float64 { return h.StdDev() })
+	return columns, rows
+}
+
+//printSummary writes the percentile table
+func (res *Result) printSummary(w io.Writer) {
+	columns, rows := res.summary()
+	fmt.Fprintf(w, "%-10s", "")
+	for _, c := range columns {
+		fmt.Fprintf(w, "  %12s", c)
+	}
+	fmt.Fprintln(w)
+	for _, r := range rows {
+		fmt.Fprintf(w, "%-10s", r.name)
+		for _, v := range r.values {
+			fmt.Fprintf(w, "  %12v", v)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+//printStages writes the statistics of every stage of the load profile
+func (res *Result) printStages(w io.Writer) {
+	if len(res.Stages) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%-5s  %-12s  %10s  %8s  %10s  %10s  %10s  %10s\n",
+		"stage", "target", "requests", "errors", "qps", "p50", "p99", "max")
+	for i, s := range res.Stages {
+		h := s.Histogram
+		fmt.Fprintf(w, "%-5d  %-12v  %10d  %8d  %10.1f  %10v  %10v  %10v\n", i+1, s.Stage, s.Requests, s.Errors, s.QPS(),
+			roundDuration(time.Duration(h.Percentile(50))), roundDuration(time.Duration(h.Percentile(99))),
+			roundDuration(time.Duration(h.Percentile(100))))
+	}
+}
+
+//percentileName formats q like p99 or p99.9
+func percentileName(q float64) string {
+	return "p" + strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+//roundDuration keeps 4 significant digits of d to make it readable
+func roundDuration(d time.Duration) time.Duration {
+	unit := time.Duration(1)
+	for d >= 10000*unit && unit < time.Second {
+		unit *= 10
+	}
+	return d.Round(unit)
+}
+
+//printErrors writes the errors grouped by message, the most frequent first
+func (res *Result) printErrors(w io.Writer) {
+	timeouts := ""
+	if res.Timeouts > 0 {
+		timeouts = fmt.Sprintf("  Timeouts: %d (%s)", res.Timeouts, percent(res.Timeouts, res.Requests))
+	}
+	fmt.Fprintf(w, "Requests: %d  Errors: %d (%s)%s  QPS: %.1f  Elapsed: %v\n", res.Requests, res.Errors,
+		percent(res.Errors, res.Requests), timeouts, res.QPS(), roundDuration(res.Elapsed))
+	for _, msg := range res.failureMessages() {
+		n := res.Failures[msg]
+		fmt.Fprintf(w, "  %8d  %6s  %s\n", n, percent(n, res.Errors), msg)
+	}
+}
+
+//failureMessages returns the error messages, the most frequent first
+func (res *Result) failureMessages() []string {
+	msgs := make([]string, 0, len(res.Failures))
+	for msg := range res.Failures {
+		msgs = append(msgs, msg)
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if res.Failures[msgs[i]] != res.Failures[msgs[j]] {
+			return res.Failures[msgs[i]] > res.Failures[msgs[j]]
+		}
+		return msgs[i] < msgs[j]
+	})
+	return msgs
+}
+
+//percent formats n/total as a percentage
+func percent(n, total int64) string {
+	if total == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+}
@@ -0,0 +1,858 @@
+package fperf
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	hist "github.com/fperf/fperf/stats"
+	"golang.org/x/net/context"
+)
+
+//Config is the typed configuration of a benchmark, it mirrors the command line options of Main
+type Config struct {
+	Connection int           `json:"connection"` //number of connections
+	Stream     int           `json:"stream"`     //number of streams per connection
+	Goroutine  int           `json:"goroutine"`  //number of goroutines per stream or connection
+	Burst      int           `json:"burst"`      //burst a number of requests, used with Async
+	N          int           `json:"n"`          //number of requests per goroutine, 0 means unlimited
+	Tick       time.Duration `json:"tick"`       //interval between statistics
+	Duration   time.Duration `json:"duration"`   //stop the benchmark after the duration, 0 means unlimited
+	Address    string        `json:"address"`    //address of the target server, multiple addresses are separated by ';'
+	Send       bool          `json:"send"`       //perform send action of streams
+	Recv       bool          `json:"recv"`       //perform recv action of streams
+	Delay      time.Duration `json:"delay"`      //wait delay time before sending the next request
+	Timeout    time.Duration `json:"timeout"`    //a request lasting longer fails as a timeout, 0 means unlimited
+	Rate       Rate          `json:"rate"`       //aggregate requests per second of all the workers in open-loop mode, 0 means closed-loop
+	Correct    bool          `json:"correct"`    //back-fill the samples omitted by stalled requests in closed-loop mode, the expected interval is the median latency plus Delay
+	Async      bool          `json:"async"`      //send and recv in separate goroutines
+	Target     string        `json:"target"`     //name of the registered client
+	Args       []string      `json:"args"`       //args parsed by the FlagSet of the client
+	CallType   string        `json:"call_type"`  //unary, stream or auto
+	Warmup     Warmup        `json:"warmup"`     //the samples of the warm-up are discarded, Duration and N include it
+	Stages     Stages        `json:"stages"`     //load profile replacing Goroutine or Rate, the benchmark stops at the end of the stages
+	Mix        Mix           `json:"mix"`        //weighted clients or operations replacing Target, every entry has its statistics
+
+	//Histogram is the layout of the latency histogram
+	Histogram hist.HistogramOptions `json:"histogram"`
+
+	//Exporters receive the statistics of every tick
+	Exporters []Exporter `json:"-"`
+}
+
+//DefaultConfig returns a Config with the same defaults as the command line
+func DefaultConfig() Config {
+	return Config{
+		Connection: 1,
+		Stream:     1,
+		Goroutine:  1,
+		Tick:       2 * time.Second,
+		Address:    "127.0.0.1:8804",
+		Send:       true,
+		Recv:       true,
+		CallType:   "auto",
+		Histogram: hist.HistogramOptions{
+			SignificantDigits: 3,
+		},
+	}
+}
+
+//Result is the outcome of a benchmark
+type Result struct {
+	Config    Config           `json:"config"`    //the configuration of the benchmark
+	Start     time.Time        `json:"start"`     //when the measurement started, after the warm-up
+	Elapsed   time.Duration    `json:"elapsed"`   //wall time of the measurement
+	Requests  int64            `json:"requests"`  //number of requests issued
+	Errors    int64            `json:"errors"`    //number of requests failed
+	Timeouts  int64            `json:"timeouts"`  //number of the errors which are timeouts
+	Sent      int64            `json:"sent"`      //bytes sent, reported by the outcomes of the requests
+	Received  int64            `json:"received"`  //bytes received
+	Failures  map[string]int64 `json:"failures"`  //number of errors grouped by message
+	Ticks     []Tick           `json:"ticks"`     //statistics of every tick
+	Histogram *hist.Histogram  `json:"histogram"` //latency histogram in nanoseconds
+	Stages    []*StageResult   `json:"stages,omitempty"`
+	Outcomes  []*OutcomeResult `json:"outcomes,omitempty"` //latencies by operation and status
+
+	//Operations is the statistics of every operation reported by the outcomes
+	Operations []*OperationResult `json:"operations,omitempty"`
+
+	//Corrected is the latency histogram corrected for coordinated omission, it is
+	//measured from the intended send time in open-loop mode, or back-filled with
+	//Config.Correct. It is nil if neither is used
+	Corrected *hist.Histogram `json:"corrected,omitempty"`
+}
+
+//roundtrip will be used in async mode
+//the sender and receiver will be in seperate goroutines
+type roundtrip struct {
+	intended time.Time
+	start    time.Time
+	end      time.Time
+}
+
+//runner holds the state of a single benchmark
+type runner struct {
+	conf Config
+
+	mu         sync.Mutex
+	recorders  []*recorder
+	histogram  *hist.Histogram
+	corrected  *hist.Histogram
+	requests   int64
+	errors     int64
+	timeouts   int64
+	sent       int64
+	received   int64
+	failures   map[string]int64
+	outcomes   map[outcomeKey]*OutcomeResult
+	operations map[string]*OperationResult
+	start      time.Time
+	measured   time.Time //the end of the warm-up
+	ticks      []Tick
+
+	rtts   chan *roundtrip
+	burst  chan int
+	pacer  *pacer
+	warmup *warmup
+
+	stages   []*StageResult
+	stageCut chan int //the index of the next stage, to cut a tick at the end of a stage
+}
+
+//Run runs a benchmark described by conf and returns the result when all
+//the requests are done, conf.Duration is elapsed or ctx is canceled
+func Run(ctx context.Context, conf Config) (*Result, error) {
+	r, err := newRunner(conf)
+	if err != nil {
+		return nil, err
+	}
+	return r.benchmark(ctx)
+}
+
+func newRunner(conf Config) (*runner, error) {
+	if conf.Connection <= 0 || conf.Stream <= 0 || conf.Goroutine <= 0 {
+		return nil, errors.New("connection, stream and goroutine should be greater than 0")
+	}
+	if conf.Tick <= 0 {
+		return nil, errors.New("tick should be greater than 0")
+	}
+	if conf.Rate < 0 {
+		return nil, errors.New("rate should not be negative")
+	}
+	if err := conf.Histogram.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid histogram: %v", err)
+	}
+	if conf.Duration < 0 {
+		return nil, errors.New("duration should not be negative")
+	}
+	if conf.Timeout < 0 {
+		return nil, errors.New("timeout should not be negative")
+	}
+	if conf.Warmup.Duration < 0 || conf.Warmup.Requests < 0 {
+		return nil, errors.New("warmup should not be negative")
+	}
+	if err := conf.Stages.validate(); err != nil {
+		return nil, err
+	}
+	if conf.Stages.rated() && conf.Rate > 0 {
+		return nil, errors.New("rate stages replace the rate")
+	}
+	if len(conf.Mix) > 0 {
+		if err := conf.Mix.validate(); err != nil {
+			return nil, err
+		}
+		for _, e := range conf.Mix {
+			if clients[e.Client] == nil {
+				return nil, fmt.Errorf("can not find client %q for benchmark", e.Client)
+			}
+		}
+		//the reports and the metrics are labelled with the mix
+		if conf.Target == "" {
+			conf.Target = conf.Mix.String()
+		}
+	} else if clients[conf.Target] == nil {
+		return nil, fmt.Errorf("can not find client %q for benchmark", conf.Target)
+	}
+
+	r := &runner{
+		conf:       conf,
+		failures:   make(map[string]int64),
+		outcomes:   make(map[outcomeKey]*OutcomeResult),
+		operations: make(map[string]*OperationResult),
+		warmup:     newWarmup(conf.Warmup),
+	}
+	if conf.Async {
+		r.rtts = make(chan *roundtrip, 10*1024*1024)
+	}
+	if conf.Burst > 0 {
+		r.burst = make(chan int, conf.Burst)
+	}
+	r.histogram = hist.NewHistogram(conf.Histogram)
+	if conf.Rate > 0 || conf.Stages.rated() {
+		//rate stages start from a paused pacer
+		r.pacer = newPacer(conf.Rate)
+	}
+	if r.pacer != nil || conf.Correct {
+		r.corrected = hist.NewHistogram(conf.Histogram)
+	}
+	if len(conf.Stages) > 0 {
+		r.stageCut = make(chan int)
+		for _, stage := range conf.Stages {
+			r.stages = append(r.stages, &StageResult{Stage: stage, Histogram: hist.NewHistogram(conf.Histogram)})
+		}
+	}
+	return r, nil
+}
+
+func (r *runner) benchmark(ctx context.Context) (*Result, error) {
+	conf := r.conf
+	clients, err := r.createClients(conf.Connection, conf.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, cli := range clients {
+			closeClient(cli, "client")
+		}
+	}()
+
+	//the result reports the call type actually used
+	if conf.CallType == "auto" {
+		switch clients[0].(type) {
+		case StreamClient:
+			r.conf.CallType = "stream"
+		case UnaryClient:
+			r.conf.CallType = "unary"
+		default:
+			return nil, fmt.Errorf("%s implements neither fperf.UnaryClient nor fperf.StreamClient", conf.Target)
+		}
+	}
+	if cli, ok := clients[0].(SetupClient); ok {
+		if err := cli.Setup(ctx); err != nil {
+			return nil, fmt.Errorf("setup: %v", err)
+		}
+	}
+	if cli, ok := clients[0].(TeardownClient); ok {
+		defer func() {
+			//the fixtures are cleaned up even if the benchmark is interrupted
+			if err := cli.Teardown(context.Background()); err != nil {
+				log.Println("teardown:", err)
+			}
+		}()
+	}
+
+	//the duration does not include the dialing and the setup
+	duration := conf.Duration
+	if d := conf.Stages.duration(); d > 0 && (duration == 0 || d < duration) {
+		duration = d
+	}
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+	for _, e := range conf.Exporters {
+		if e, ok := e.(StartExporter); ok {
+			if err := e.Start(r.conf); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	r.start = time.Now()
+	if r.warmup != nil {
+		r.warmup.start(conf.Warmup.Duration)
+	} else {
+		r.measured = r.start
+	}
+	switch r.conf.CallType {
+	case "stream":
+		err = r.runStream(ctx, clients)
+	case "unary":
+		err = r.benchmarkUnary(ctx, conf.Goroutine, clients)
+	default:
+		err = fmt.Errorf("unknown call type %q", conf.CallType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	end := time.Now()
+	if r.measured.IsZero() {
+		//the benchmark ended during the warm-up
+		r.measured = end
+	}
+	return &Result{
+		Config:     r.conf,
+		Start:      r.measured,
+		Elapsed:    end.Sub(r.measured),
+		Requests:   r.requests,
+		Errors:     r.errors,
+		Timeouts:   r.timeouts,
+		Sent:       r.sent,
+		Received:   r.received,
+		Failures:   r.failures,
+		Ticks:      r.ticks,
+		Histogram:  r.histogram,
+		Stages:     r.stages,
+		Outcomes:   r.outcomeResults(),
+		Operations: r.operationResults(),
+		Corrected:  r.corrected,
+	}, nil
+}
+
+//create the testcase clients, n is the number of clients, set by
+//flag -connection
+func (r *runner) createClients(n int, addr string) ([]Client, error) {
+	addrs := strings.Split(addr, ";")
+	getAddr := func(addrs []string) func() string {
+		i := -1
+		size := len(addrs)
+		return func() string {
+			i++
+			return addrs[i%size]
+		}
+	}(addrs)
+	//never fall back to the command line when used as a library
+	args := r.conf.Args
+	if args == nil {
+		args = []string{}
+	}
+	clients := make([]Client, n)
+	for i := 0; i < n; i++ {
+		addr := getAddr()
+		var cli Client
+		var err error
+		if len(r.conf.Mix) > 0 {
+			cli, err = r.dialMix(addr, args)
+		} else {
+			cli, err = r.dial(r.conf.Target, addr, args)
+		}
+		if err != nil {
+			for _, cli := range clients[:i] {
+				closeClient(cli, "client")
+			}
+			return nil, err
+		}
+		clients[i] = cli
+	}
+	return clients, nil
+}
+
+//dial creates the client registered by name and dials it to addr
+func (r *runner) dial(name, addr string, args []string) (Client, error) {
+	cli := newClient(name, args)
+	if cli == nil {
+		return nil, fmt.Errorf("can not find client %q for benchmark", name)
+	}
+	if err := cli.Dial(addr); err != nil {
+		return nil, fmt.Errorf("%s: %v", addr, err)
+	}
+	return cli, nil
+}
+
+//create streams for every client. n is the number of streams per client
+func (r *runner) createStreams(ctx context.Context, n int, clients []Client) ([]Stream, error) {
+	streams := make([]Stream, n*len(clients))
+	for cur, cli := range clients {
+		for i := 0; i < n; i++ {
+			if cli, ok := cli.(StreamClient); ok {
+				stream, err := cli.CreateStream(ctx)
+				if err != nil {
+					//the streams created are closed by the caller
+					return streams, fmt.Errorf("StreamCall faile to create new stream, %v", err)
+				}
+				streams[cur*n+i] = stream
+			} else {
+				return streams, fmt.Errorf("%s do not implement the fperf.StreamClient", r.conf.Target)
+			}
+		}
+	}
+	return streams, nil
+}
+
+func (r *runner) runStream(ctx context.Context, clients []Client) error {
+	if len(r.conf.Stages) > 0 && !r.conf.Stages.rated() {
+		return errors.New("stages of workers need a unary client, use stages of rates like 30s:100/s for streams")
+	}
+	streams, err := r.createStreams(ctx, r.conf.Stream, clients)
+	defer func() {
+		for _, stream := range streams {
+			if stream != nil {
+				closeClient(stream, "stream")
+			}
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	r.benchmarkStream(ctx, r.conf.Goroutine, streams)
+	return nil
+}
+
+//run benchmark for stream clients, can be in async or sync mode
+func (r *runner) benchmarkStream(ctx context.Context, n int, streams []Stream) {
+	var wg sync.WaitGroup
+	done := ctx.Done()
+	if r.pacer != nil {
+		r.pacer.start()
+	}
+	r.followRates(done, &wg)
+	for _, stream := range streams {
+		for i := 0; i < n; i++ {
+			//Notice here. we must pass stream as a parameter because the varibale stream
+			//would be changed after the goroutine created
+			rec := r.newRecorder()
+			if r.conf.Async {
+				wg.Add(2)
+				go func(stream Stream) { r.send(ctx, stream, rec); wg.Done() }(stream)
+				go func(stream Stream) { r.recv(ctx, stream, rec); wg.Done() }(stream)
+			} else {
+				wg.Add(1)
+				go func(stream Stream) { r.run(ctx, stream, rec); wg.Done() }(stream)
+			}
+		}
+	}
+	r.wait(&wg)
+}
+
+//run benchmark for unary clients
+func (r *runner) benchmarkUnary(ctx context.Context, n int, clients []Client) error {
+	unaries := make([]UnaryClient, len(clients))
+	for i, cli := range clients {
+		unary, ok := cli.(UnaryClient)
+		if !ok {
+			return fmt.Errorf("%s does not implement the fperf.UnaryClient", r.conf.Target)
+		}
+		unaries[i] = unary
+	}
+	if len(r.conf.Stages) > 0 && !r.conf.Stages.rated() {
+		r.benchmarkStaged(ctx, unaries)
+		return nil
+	}
+	var wg sync.WaitGroup
+	done := ctx.Done()
+	if r.pacer != nil {
+		r.pacer.start()
+	}
+	r.followRates(done, &wg)
+	for _, cli := range clients {
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			rec := r.newRecorder()
+			go func(cli UnaryClient) { r.runUnary(ctx, done, cli, rec); wg.Done() }(cli.(UnaryClient))
+		}
+	}
+	r.wait(&wg)
+	return nil
+}
+
+//newRecorder creates a recorder for a worker and attaches it to the collector
+func (r *runner) newRecorder() *recorder {
+	rec := newRecorder(r.pacer != nil)
+	if r.warmup != nil {
+		rec.warmup = r.warmup
+		rec.warm = newRecorder(r.pacer != nil)
+	}
+	r.mu.Lock()
+	r.recorders = append(r.recorders, rec)
+	r.mu.Unlock()
+	return rec
+}
+
+//collect swaps the samples out of all the recorders
+func (r *runner) collect(snap *snapshot) {
+	r.mu.Lock()
+	recorders := r.recorders
+	r.mu.Unlock()
+	for _, rec := range recorders {
+		rec.collect(snap)
+	}
+	//the requests sent by the send goroutines and not received yet
+	snap.inflight += int64(len(r.rtts))
+}
+
+//collectWarmup swaps the samples of the warm-up out of all the recorders
+func (r *runner) collectWarmup(snap *snapshot) {
+	r.mu.Lock()
+	recorders := r.recorders
+	r.mu.Unlock()
+	for _, rec := range recorders {
+		rec.warm.collect(snap)
+	}
+}
+
+//pace blocks until the next request is scheduled in open-loop mode, it returns
+//the intended send time, or now in closed-loop mode. It returns false if done
+//is closed before that
+func (r *runner) pace(done <-chan struct{}) (time.Time, bool) {
+	if r.pacer == nil {
+		return time.Now(), true
+	}
+	return r.pacer.wait(done)
+}
+
+//wait for the workers to exit while printing the statistics
+func (r *runner) wait(wg *sync.WaitGroup) {
+	stop := make(chan struct{})
+	exited := make(chan struct{})
+	go func() { r.statPrint(stop); close(exited) }()
+	wg.Wait()
+	close(stop)
+	<-exited
+}
+
+//sleep waits for d, it returns false if done is closed before that
+func sleep(done <-chan struct{}, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+//request calls f with the context of a request and records it, a request interrupted
+//by the end of the benchmark is not recorded. A request lasting longer than the timeout
+//is a timeout even if f ignores the context
+func (r *runner) request(ctx context.Context, rec *recorder, intended time.Time, f func(ctx context.Context) (Outcome, error)) {
+	reqCtx, cancel := r.requestContext(ctx)
+	start := time.Now()
+	rec.begin()
+	out, err := f(reqCtx)
+	end := time.Now()
+	rec.end()
+	cancel()
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return
+	case err != nil && reqCtx.Err() == context.DeadlineExceeded:
+		err = ErrTimeout
+	case err == nil && r.conf.Timeout > 0 && end.Sub(start) > r.conf.Timeout:
+		err = ErrTimeout
+	}
+	rec.recordOutcome(end.Sub(start), end.Sub(intended), out, err)
+}
+
+//requestContext returns the context of a request, it is canceled at the timeout or
+//with ctx at the end of the benchmark
+func (r *runner) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.conf.Timeout > 0 {
+		return context.WithTimeout(ctx, r.conf.Timeout)
+	}
+	return ctx, func() {}
+}
+
+//runUnary sends requests by cli until done is closed, ctx is the context of the benchmark
+func (r *runner) runUnary(ctx context.Context, done <-chan struct{}, cli UnaryClient, rec *recorder) {
+	call := unaryCall(cli)
+	for i := 0; r.conf.N == 0 || i < r.conf.N; i++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		intended, ok := r.pace(done)
+		if !ok {
+			return
+		}
+		r.request(ctx, rec, intended, call)
+		if !sleep(done, r.conf.Delay) {
+			return
+		}
+	}
+}
+
+func (r *runner) run(ctx context.Context, stream Stream, rec *recorder) {
+	done := ctx.Done()
+	send, recv := streamCalls(stream)
+	call := func(ctx context.Context) (Outcome, error) {
+		var err error
+		if r.conf.Send {
+			err = send(ctx)
+		}
+		if err == nil && r.conf.Recv {
+			err = recv(ctx)
+		}
+		return Outcome{}, err
+	}
+	for i := 0; r.conf.N == 0 || i < r.conf.N; i++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		intended, ok := r.pace(done)
+		if !ok {
+			return
+		}
+		r.request(ctx, rec, intended, call)
+		if !sleep(done, r.conf.Delay) {
+			return
+		}
+	}
+}
+
+//send sends requests without waiting for the responses, the timeout applies to every send
+func (r *runner) send(ctx context.Context, stream Stream, rec *recorder) {
+	done := ctx.Done()
+	send, _ := streamCalls(stream)
+	timer := time.NewTimer(time.Second)
+	defer timer.Stop()
+	for i := 0; r.conf.N == 0 || i < r.conf.N; i++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		intended, ok := r.pace(done)
+		if !ok {
+			return
+		}
+		select {
+		case r.rtts <- &roundtrip{intended: intended, start: time.Now()}:
+			timer.Reset(time.Second)
+		case <-timer.C:
+			log.Println("blocked on send rtts")
+		case <-done:
+			return
+		}
+
+		if r.burst != nil {
+			select {
+			case r.burst <- 0:
+				timer.Reset(time.Second)
+			case <-timer.C:
+				log.Println("blocked on send burst chan")
+			case <-done:
+				return
+			}
+		}
+
+		sendCtx, cancel := r.requestContext(ctx)
+		err := send(sendCtx)
+		if err != nil && ctx.Err() == nil {
+			if sendCtx.Err() == context.DeadlineExceeded {
+				err = ErrTimeout
+			}
+			rec.fail(err)
+		}
+		cancel()
+		if !sleep(done, r.conf.Delay) {
+			return
+		}
+	}
+}
+
+//recv receives the responses, one arriving later than the timeout after its request
+//is a timeout
+func (r *runner) recv(ctx context.Context, stream Stream, rec *recorder) {
+	done := ctx.Done()
+	_, recv := streamCalls(stream)
+	timer := time.NewTimer(time.Second)
+	defer timer.Stop()
+	for i := 0; r.conf.N == 0 || i < r.conf.N; i++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if r.burst != nil {
+			select {
+			case <-r.burst:
+				timer.Reset(time.Second)
+			case <-timer.C:
+				log.Println("blocked on recv burst chan")
+			case <-done:
+				return
+			}
+		}
+		err := recv(ctx)
+		if err != nil {
+			select {
+			case <-done:
+				//the stream is canceled with the context
+			default:
+				log.Println("recv goroutine exit", err)
+				rec.fail(err)
+			}
+			return
+		}
+		select {
+		case rtt := <-r.rtts:
+			timer.Reset(time.Second)
+			end := time.Now()
+			if r.conf.Timeout > 0 && end.Sub(rtt.start) > r.conf.Timeout {
+				err = ErrTimeout
+			}
+			rec.record(end.Sub(rtt.start), end.Sub(rtt.intended), err)
+		case <-timer.C:
+			log.Println("blocked on recv rtts")
+		case <-done:
+			return
+		}
+		if !sleep(done, r.conf.Delay) {
+			return
+		}
+	}
+}
+
+//correct adds the samples of snap to the corrected histogram and returns the
+//mean of the corrected latencies, it does nothing if the correction is disabled
+func (r *runner) correct(snap *snapshot) time.Duration {
+	if r.corrected == nil {
+		return 0
+	}
+	sum, count := r.corrected.Sum, r.corrected.Count
+	if r.pacer != nil {
+		//measured from the intended send time, nothing to back-fill
+		for _, eplase := range snap.corrected {
+			addLatency(r.corrected, eplase)
+		}
+	} else {
+		//a closed-loop worker sends every service time plus the delay, so only the
+		//requests stalled well beyond the typical service time hide samples
+		interval := r.histogram.Percentile(50) + int64(r.conf.Delay)
+		for _, eplase := range snap.latencies {
+			if err := r.corrected.AddWithExpectedInterval(int64(eplase), interval); err != nil {
+				log.Println("histogram:", err)
+			}
+		}
+	}
+	if r.corrected.Count == count {
+		return 0
+	}
+	return time.Duration((r.corrected.Sum - sum) / (r.corrected.Count - count))
+}
+
+//export sends the tick to the exporters, a failed exporter does not stop the benchmark
+func (r *runner) export(tick Tick) {
+	for _, e := range r.conf.Exporters {
+		if err := e.Export(tick); err != nil {
+			log.Println("export:", err)
+		}
+	}
+}
+
+//statPrint prints the statistics every tick until stop is closed, the samples of
+//the last incomplete tick are flushed before returning
+func (r *runner) statPrint(stop <-chan struct{}) {
+	ticker := time.NewTicker(r.conf.Tick)
+	defer ticker.Stop()
+	snap := &snapshot{
+		latencies: make([]time.Duration, 0, 500000),
+		failures:  make(map[string]int64),
+	}
+	//closed at the end of the warm-up, nil once it is over
+	var warming <-chan struct{}
+	var warm *snapshot
+	if r.warmup != nil {
+		warming = r.warmup.done
+		warm = &snapshot{failures: make(map[string]int64)}
+	}
+	//the samples of a tick belong to the stage before the cut
+	stage, next := 0, 0
+	last := time.Now()
+	for {
+		final, cut := false, false
+		select {
+		case <-ticker.C:
+		case <-warming:
+			//cut a tick at the end of the warm-up
+		case next = <-r.stageCut:
+			cut = true
+		case <-stop:
+			final = true
+		}
+		now := time.Now()
+		interval := now.Sub(last)
+		last = now
+
+		snap.reset()
+		r.collect(snap)
+		//the recorders keep the samples of the warm-up apart, they are discarded
+		ended := false
+		if warm != nil {
+			warm.reset()
+			r.collectWarmup(warm)
+			warm.inflight = snap.inflight
+			warmInterval := interval
+			if warming != nil {
+				select {
+				case <-warming:
+					//the samples of the tick after the end are measured
+					warming = nil
+					ended = true
+					r.measured = r.warmup.ended
+					warmInterval = interval - now.Sub(r.measured)
+					interval = now.Sub(r.measured)
+				default:
+				}
+			}
+			if warming != nil || warm.requests != 0 || warm.errors != 0 {
+				tick := newTick(warm, now, warmInterval)
+				tick.Elapsed = now.Sub(r.start)
+				tick.Warmup = true
+				r.emit(tick, final)
+			}
+		}
+		if warming == nil {
+			tick := newTick(snap, now, interval)
+			tick.Elapsed = now.Sub(r.start)
+			tick.Operations = operationTicks(snap, now, interval)
+			r.requests += snap.requests
+			r.errors += snap.errors
+			r.timeouts += snap.timeouts
+			r.sent += snap.sent
+			r.received += snap.received
+			r.addOutcomes(snap)
+			r.addOperations(snap)
+			mergeFailures(r.failures, snap.failures)
+			for _, eplase := range snap.latencies {
+				addLatency(r.histogram, eplase)
+			}
+			tick.Corrected = r.correct(snap)
+			if r.stages != nil {
+				r.stages[stage].add(snap, interval)
+			}
+			//the end of the warm-up cuts a tick of the warm-up only
+			if !ended || tick.Requests != 0 || tick.Errors != 0 {
+				r.emit(tick, final)
+			}
+		}
+		if cut {
+			stage = next
+		}
+		if final {
+			return
+		}
+	}
+}
+
+//emit logs, exports and keeps a tick, the last tick is dropped if it is empty
+func (r *runner) emit(tick Tick, final bool) {
+	empty := tick.Requests == 0 && tick.Errors == 0
+	switch {
+	case tick.Warmup && !empty:
+		log.Printf("warmup %v\n", tick)
+	case !empty:
+		log.Printf("%v total %v\n", tick, r.requests)
+		logOperations(tick.Operations)
+	case !final:
+		log.Printf("blocking...")
+	}
+	if !empty || !final {
+		r.export(tick)
+		//the samples are reused by the next tick
+		tick.latencies = nil
+		r.ticks = append(r.ticks, tick)
+	}
+}
@@ -0,0 +1,427 @@
+package fperf
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type unarycli struct {
+	addr     string
+	requests *int64
+	fail     bool
+}
+
+func (c *unarycli) Dial(addr string) error {
+	c.addr = addr
+	return nil
+}
+
+func (c *unarycli) Request() error {
+	atomic.AddInt64(c.requests, 1)
+	if c.fail {
+		return errors.New("request failed")
+	}
+	return nil
+}
+
+func registerUnary(name string, requests *int64, fail bool) {
+	Register(name, func(flag *FlagSet) Client {
+		return &unarycli{requests: requests, fail: fail}
+	})
+}
+
+func TestRun(t *testing.T) {
+	var requests int64
+	registerUnary("test-unary", &requests, false)
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary"
+	conf.Connection = 2
+	conf.Goroutine = 3
+	conf.N = 100
+	conf.Tick = 10 * time.Millisecond
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 600 || requests != 600 {
+		t.Fatalf("expect 600 requests, got %d, issued %d", res.Requests, requests)
+	}
+	if res.Errors != 0 {
+		t.Fatalf("expect no errors, got %d", res.Errors)
+	}
+	if res.Histogram.Count != 600 {
+		t.Fatalf("expect 600 samples in histogram, got %d", res.Histogram.Count)
+	}
+}
+
+func TestRunArgs(t *testing.T) {
+	var requests int64
+	var keys []string
+	var mu sync.Mutex
+	Register("test-unary-args", func(flag *FlagSet) Client {
+		key := flag.String("key", "", "")
+		flag.Parse()
+		mu.Lock()
+		keys = append(keys, *key)
+		mu.Unlock()
+		return &unarycli{requests: &requests}
+	})
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-args"
+	conf.Connection = 2
+	conf.N = 1
+	conf.Args = []string{"-key", "foo"}
+	if _, err := Run(context.Background(), conf); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "foo" || keys[1] != "foo" {
+		t.Fatalf("expect the args parsed by every client, got %v", keys)
+	}
+}
+
+type streamcli struct {
+	requests *int64
+}
+
+type teststream struct {
+	requests *int64
+}
+
+func (c *streamcli) Dial(addr string) error {
+	return nil
+}
+
+func (c *streamcli) CreateStream(ctx context.Context) (Stream, error) {
+	return &teststream{requests: c.requests}, nil
+}
+
+func (s *teststream) DoSend() error {
+	atomic.AddInt64(s.requests, 1)
+	return nil
+}
+
+func (s *teststream) DoRecv() error {
+	return nil
+}
+
+func TestRunConcurrent(t *testing.T) {
+	var requests int64
+	registerUnary("test-unary-concurrent", &requests, false)
+	var sent int64
+	Register("test-stream-concurrent", func(flag *FlagSet) Client {
+		return &streamcli{requests: &sent}
+	})
+
+	conf := DefaultConfig()
+	conf.Connection = 4
+	conf.Stream = 2
+	conf.Goroutine = 8
+	conf.N = 5000
+	conf.Tick = time.Millisecond
+	for _, target := range []string{"test-unary-concurrent", "test-stream-concurrent"} {
+		conf.Target = target
+		res, err := Run(context.Background(), conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Histogram.Count != res.Requests {
+			t.Fatalf("%s: expect %d samples in histogram, got %d", target, res.Requests, res.Histogram.Count)
+		}
+	}
+	if requests != 4*8*5000 {
+		t.Fatalf("expect %d unary requests, got %d", 4*8*5000, requests)
+	}
+	if sent != 4*2*8*5000 {
+		t.Fatalf("expect %d stream requests, got %d", 4*2*8*5000, sent)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	var requests int64
+	registerUnary("test-unary-fail", &requests, true)
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-fail"
+	conf.N = 10
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Errors != 10 {
+		t.Fatalf("expect 10 errors, got %d", res.Errors)
+	}
+	if res.Failures["request failed"] != 10 {
+		t.Fatalf("expect errors grouped by message, got %v", res.Failures)
+	}
+	if res.Histogram.Count != 0 {
+		t.Fatalf("expect failed requests excluded from histogram, got %d", res.Histogram.Count)
+	}
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	conf := DefaultConfig()
+	conf.Target = "not-registered"
+	if _, err := Run(context.Background(), conf); err == nil {
+		t.Fatal("expect error for unregistered client")
+	}
+
+	conf = DefaultConfig()
+	conf.Target = "test-unary"
+	conf.Connection = 0
+	if _, err := Run(context.Background(), conf); err == nil {
+		t.Fatal("expect error for zero connection")
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	var requests int64
+	registerUnary("test-unary-duration", &requests, false)
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-duration"
+	conf.Delay = time.Millisecond
+	conf.Duration = 50 * time.Millisecond
+	conf.Tick = 10 * time.Millisecond
+	start := time.Now()
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("run should stop after 50ms, took %v", elapsed)
+	}
+	if res.Requests == 0 || res.Requests != res.Histogram.Count {
+		t.Fatalf("expect all requests in histogram, requests %d histogram %d", res.Requests, res.Histogram.Count)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	var requests int64
+	registerUnary("test-unary-cancel", &requests, false)
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-cancel"
+	conf.Delay = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	res, err := Run(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 1 {
+		t.Fatalf("expect 1 request before the delay, got %d", res.Requests)
+	}
+}
+
+type slowcli struct {
+	delay time.Duration
+}
+
+func (c *slowcli) Dial(addr string) error {
+	return nil
+}
+
+func (c *slowcli) Request() error {
+	time.Sleep(c.delay)
+	return nil
+}
+
+func TestRunCorrected(t *testing.T) {
+	Register("test-unary-slow", func(flag *FlagSet) Client {
+		return &slowcli{delay: 10 * time.Millisecond}
+	})
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-slow"
+	conf.Duration = 200 * time.Millisecond
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Corrected != nil {
+		t.Fatal("expect no corrected histogram in closed-loop mode")
+	}
+
+	//the server can only serve 100/s, latencies pile up from the intended send time
+	conf.Rate = 1000
+	res, err = Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := res.Histogram.Sum / res.Histogram.Count
+	corrected := res.Corrected.Sum / res.Corrected.Count
+	if res.Corrected.Count != res.Histogram.Count || corrected < 5*raw {
+		t.Fatalf("expect corrected latency far above %v, got %v", time.Duration(raw), time.Duration(corrected))
+	}
+
+	//the requests are not stalled, there is nothing to back-fill
+	conf.Rate = 0
+	conf.Correct = true
+	conf.Delay = time.Millisecond
+	conf.Duration = 0
+	conf.N = 20
+	res, err = Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Corrected.Count != res.Histogram.Count || res.Corrected.Sum != res.Histogram.Sum {
+		t.Fatalf("expect corrected equal to raw, got %d corrected %d raw", res.Corrected.Count, res.Histogram.Count)
+	}
+
+	//a single stalled request hides the samples of the requests it delays
+	Register("test-unary-stall", func(flag *FlagSet) Client {
+		return &stallcli{slowcli: slowcli{delay: 5 * time.Millisecond}, stall: 10, stallDelay: 100 * time.Millisecond}
+	})
+	conf.Target = "test-unary-stall"
+	res, err = Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//100ms stalled with a request expected every 6ms
+	if filled := res.Corrected.Count - res.Histogram.Count; filled < 10 || filled > 20 {
+		t.Fatalf("expect the samples of the stall back-filled, got %d corrected %d raw", res.Corrected.Count, res.Histogram.Count)
+	}
+}
+
+//stallcli is a slowcli stalling on a single request
+type stallcli struct {
+	slowcli
+	requests   int
+	stall      int
+	stallDelay time.Duration
+}
+
+func (c *stallcli) Request() error {
+	c.requests++
+	if c.requests == c.stall {
+		time.Sleep(c.stallDelay)
+		return nil
+	}
+	return c.slowcli.Request()
+}
+
+//hangcli never responds unless its context is canceled
+type hangcli struct{}
+
+func (c *hangcli) Dial(addr string) error {
+	return nil
+}
+
+func (c *hangcli) Request() error {
+	select {}
+}
+
+func (c *hangcli) RequestContext(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunTimeout(t *testing.T) {
+	Register("test-unary-hang", func(flag *FlagSet) Client {
+		return &hangcli{}
+	})
+
+	//the hung requests are interrupted at the end and not recorded
+	conf := DefaultConfig()
+	conf.Target = "test-unary-hang"
+	conf.Duration = 50 * time.Millisecond
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 0 {
+		t.Fatalf("expect no request recorded, got %d", res.Requests)
+	}
+
+	conf.Timeout = 10 * time.Millisecond
+	res, err = Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Timeouts == 0 || res.Timeouts != res.Errors || res.Failures[ErrTimeout.Error()] != res.Timeouts {
+		t.Fatalf("expect all the errors to be timeouts, got %d errors %d timeouts %v", res.Errors, res.Timeouts, res.Failures)
+	}
+
+	//a client ignoring the context completes the request but still times out
+	Register("test-unary-timeout", func(flag *FlagSet) Client {
+		return &slowcli{delay: 20 * time.Millisecond}
+	})
+	conf.Target = "test-unary-timeout"
+	conf.N = 2
+	conf.Duration = 0
+	res, err = Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 2 || res.Timeouts != 2 {
+		t.Fatalf("expect 2 timed out requests, got %d requests %d timeouts", res.Requests, res.Timeouts)
+	}
+
+	conf.Timeout = -1
+	if _, err := Run(context.Background(), conf); err == nil {
+		t.Fatal("expect error for negative timeout")
+	}
+}
+
+//lifecycli records the calls of the lifecycle hooks
+type lifecycli struct {
+	unarycli
+	calls    *[]string
+	setupErr error
+}
+
+func (c *lifecycli) Setup(ctx context.Context) error {
+	*c.calls = append(*c.calls, "setup")
+	return c.setupErr
+}
+
+func (c *lifecycli) Teardown(ctx context.Context) error {
+	*c.calls = append(*c.calls, "teardown")
+	return nil
+}
+
+func (c *lifecycli) Close() error {
+	*c.calls = append(*c.calls, "close")
+	return nil
+}
+
+func TestRunLifecycle(t *testing.T) {
+	var requests int64
+	var calls []string
+	var setupErr error
+	Register("test-unary-lifecycle", func(flag *FlagSet) Client {
+		return &lifecycli{unarycli: unarycli{requests: &requests}, calls: &calls, setupErr: setupErr}
+	})
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-lifecycle"
+	conf.Connection = 2
+	conf.N = 10
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 20 {
+		t.Fatalf("expect 20 requests, got %d", res.Requests)
+	}
+	if expect := "setup teardown close close"; strings.Join(calls, " ") != expect {
+		t.Fatalf("expect %q, got %q", expect, strings.Join(calls, " "))
+	}
+
+	calls, requests = nil, 0
+	setupErr = errors.New("no fixtures")
+	if _, err := Run(context.Background(), conf); err == nil {
+		t.Fatal("expect error for failed setup")
+	}
+	if requests != 0 || strings.Join(calls, " ") != "setup close close" {
+		t.Fatalf("expect no request and the clients closed, got %d requests and %q", requests, strings.Join(calls, " "))
+	}
+}
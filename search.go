@@ -0,0 +1,177 @@
+package fperf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+//Search steps up the load from Start by Step until Max, the load is the number of
+//goroutines per stream or connection, or a rate. It can be used as a flag.Value
+//in the form of "10:10:200" or "100/s:100/s:5000/s"
+type Search struct {
+	Start float64 `json:"start"`
+	Step  float64 `json:"step"`
+	Max   float64 `json:"max"`
+	Rate  bool    `json:"rate"` //whether the load is a rate
+}
+
+//Set parses the search from s
+func (s *Search) Set(str string) error {
+	fields := strings.Split(str, ":")
+	if len(fields) != 3 {
+		return fmt.Errorf("invalid search %q, should be start:step:max", str)
+	}
+	var search Search
+	values := make([]float64, 3)
+	search.Rate = strings.Contains(fields[0], "/")
+	for i, field := range fields {
+		var err error
+		if strings.Contains(field, "/") != search.Rate {
+			return fmt.Errorf("invalid search %q, should be all goroutines or all rates", str)
+		}
+		if search.Rate {
+			var rate Rate
+			err = rate.Set(field)
+			values[i] = float64(rate)
+		} else {
+			var n int
+			n, err = strconv.Atoi(field)
+			values[i] = float64(n)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid search %q: %v", str, err)
+		}
+	}
+	search.Start, search.Step, search.Max = values[0], values[1], values[2]
+	if search.Start <= 0 || search.Step <= 0 || search.Max < search.Start {
+		return fmt.Errorf("invalid search %q, should be 0 < start <= max and step > 0", str)
+	}
+	*s = search
+	return nil
+}
+
+func (s *Search) String() string {
+	if s.Step == 0 {
+		return ""
+	}
+	return s.format(s.Start) + ":" + s.format(s.Step) + ":" + s.format(s.Max)
+}
+
+//format formats a load as goroutines or a rate
+func (s *Search) format(load float64) string {
+	if s.Rate {
+		r := Rate(load)
+		return r.String()
+	}
+	return strconv.FormatFloat(load, 'f', 0, 64)
+}
+
+//defaultSearchStep is the duration of every step if -duration is not set
+const defaultSearchStep = 10 * time.Second
+
+//SearchStep is the result of a load step
+type SearchStep struct {
+	Load   float64     `json:"load"`
+	Failed []Assertion `json:"failed,omitempty"` //the violated SLOs
+	Result *Result     `json:"result"`
+}
+
+//SearchResult is the curve measured by a search, Max is the index of the step with the
+//maximum sustainable throughput, -1 if every step violates the SLOs
+type SearchResult struct {
+	Search Search       `json:"search"`
+	SLO    Assertions   `json:"slo"`
+	Steps  []SearchStep `json:"steps"`
+	Max    int          `json:"max"`
+}
+
+//RunSearch runs a benchmark of conf.Duration for every step of the load until the
+//result violates the SLOs, Max is reached or ctx is canceled
+func RunSearch(ctx context.Context, conf Config, search Search, slo Assertions) (*SearchResult, error) {
+	if search.Step <= 0 {
+		return nil, errors.New("search step should be greater than 0")
+	}
+	if len(slo) == 0 {
+		return nil, errors.New("search needs an SLO to find the knee")
+	}
+	if conf.Duration <= 0 {
+		return nil, errors.New("search needs a duration of every step")
+	}
+	if len(conf.Stages) > 0 {
+		return nil, errors.New("search can not run with stages")
+	}
+
+	sr := &SearchResult{Search: search, SLO: slo, Max: -1}
+	for load := search.Start; load <= search.Max; load += search.Step {
+		if search.Rate {
+			conf.Rate = Rate(load)
+		} else {
+			conf.Goroutine = int(load)
+		}
+		log.Printf("search step %d: %s\n", len(sr.Steps)+1, search.format(load))
+		res, err := Run(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		step := SearchStep{Load: load, Result: res}
+		for _, a := range slo {
+			if !a.Check(res) {
+				step.Failed = append(step.Failed, a)
+			}
+		}
+		sr.Steps = append(sr.Steps, step)
+		if ctx.Err() != nil {
+			//the step is cut short, the SLOs are not reliable
+			break
+		}
+		if len(step.Failed) > 0 {
+			break
+		}
+		if sr.Max < 0 || res.QPS() > sr.Steps[sr.Max].Result.QPS() {
+			sr.Max = len(sr.Steps) - 1
+		}
+	}
+	return sr, nil
+}
+
+//Print writes the curve and the maximum sustainable throughput
+func (sr *SearchResult) Print(w io.Writer) {
+	fmt.Fprintf(w, "%-12s  %10s  %10s  %10s  %10s  %8s  %s\n", "load", "qps", "p50", "p99", "max", "errors", "slo")
+	for _, step := range sr.Steps {
+		res := step.Result
+		h := res.Histogram
+		verdict := "pass"
+		if len(step.Failed) > 0 {
+			names := make([]string, len(step.Failed))
+			for i, a := range step.Failed {
+				names[i] = a.String()
+			}
+			verdict = "FAIL " + strings.Join(names, " ")
+		}
+		fmt.Fprintf(w, "%-12s  %10.1f  %10v  %10v  %10v  %8s  %s\n", sr.Search.format(step.Load), res.QPS(),
+			roundDuration(time.Duration(h.Percentile(50))), roundDuration(time.Duration(h.Percentile(99))),
+			roundDuration(time.Duration(h.Percentile(100))), percent(res.Errors, res.Requests), verdict)
+	}
+	if sr.Max < 0 {
+		fmt.Fprintf(w, "No load meets the SLO %v\n", &sr.SLO)
+		return
+	}
+	best := sr.Steps[sr.Max]
+	fmt.Fprintf(w, "Max sustainable throughput: %.1f qps at load %s\n", best.Result.QPS(), sr.Search.format(best.Load))
+}
+
+//WriteJSON writes the search result as a JSON document, the results of the steps are
+//in the format of Result.WriteJSON without the summaries
+func (sr *SearchResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sr)
+}
@@ -0,0 +1,94 @@
+package fperf
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+//queuecli serves one request at a time, the latency grows with the concurrency
+type queuecli struct {
+	mu    *sync.Mutex
+	delay time.Duration
+}
+
+func (c *queuecli) Dial(addr string) error {
+	return nil
+}
+
+func (c *queuecli) Request() error {
+	c.mu.Lock()
+	time.Sleep(c.delay)
+	c.mu.Unlock()
+	return nil
+}
+
+func TestSearchSet(t *testing.T) {
+	var s Search
+	if err := s.Set("10:10:200"); err != nil {
+		t.Fatal(err)
+	}
+	if s != (Search{Start: 10, Step: 10, Max: 200}) || s.String() != "10:10:200" {
+		t.Fatalf("unexpected search %+v", s)
+	}
+	if err := s.Set("100/s:100/s:5/ms"); err != nil {
+		t.Fatal(err)
+	}
+	if s != (Search{Start: 100, Step: 100, Max: 5000, Rate: true}) || s.String() != "100/s:100/s:5000/s" {
+		t.Fatalf("unexpected search %+v", s)
+	}
+	for _, str := range []string{"10:10", "10:10/s:100", "0:10:100", "10:0:100", "100:10:10", "a:b:c"} {
+		if err := s.Set(str); err == nil {
+			t.Fatalf("expect error for %q", str)
+		}
+	}
+}
+
+func TestRunSearch(t *testing.T) {
+	var mu sync.Mutex
+	Register("test-unary-search", func(flag *FlagSet) Client {
+		return &queuecli{mu: &mu, delay: 2 * time.Millisecond}
+	})
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-search"
+	conf.Duration = 100 * time.Millisecond
+	conf.Tick = time.Second
+	var slo Assertions
+	slo.Set("mean<5ms")
+	sr, err := RunSearch(context.Background(), conf, Search{Start: 1, Step: 1, Max: 10}, slo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the mean latency is 2ms at 1 goroutine, 4ms at 2 and 6ms at 3, while the
+	//throughput is saturated from 1 goroutine
+	if len(sr.Steps) != 3 || sr.Max < 0 || sr.Max > 1 || len(sr.Steps[2].Failed) != 1 {
+		t.Fatalf("expect the knee at 3 goroutines, got max %d of %d steps", sr.Max, len(sr.Steps))
+	}
+	buf := bytes.NewBuffer(nil)
+	sr.Print(buf)
+	if out := buf.String(); !strings.Contains(out, "FAIL mean<5ms") || !strings.Contains(out, "Max sustainable throughput") {
+		t.Fatalf("unexpected output\n%s", out)
+	}
+
+	if _, err := RunSearch(context.Background(), conf, Search{Start: 1, Step: 1, Max: 10}, nil); err == nil {
+		t.Fatal("expect error without SLO")
+	}
+	//a dead service has no latency meeting the SLO
+	var requests int64
+	registerUnary("test-unary-search-fail", &requests, true)
+	conf.Target = "test-unary-search-fail"
+	conf.Duration = 20 * time.Millisecond
+	conf.Delay = time.Millisecond
+	sr, err = RunSearch(context.Background(), conf, Search{Start: 1, Step: 1, Max: 10}, slo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sr.Steps) != 1 || sr.Max != -1 {
+		t.Fatalf("expect the first step to fail, got max %d of %d steps", sr.Max, len(sr.Steps))
+	}
+}
@@ -0,0 +1,240 @@
+package fperf
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	hist "github.com/fperf/fperf/stats"
+	"golang.org/x/net/context"
+)
+
+//Stage ramps the load linearly from the target of the previous stage, or 0 for the
+//first stage, to its own target over its duration. The target is either a number of
+//workers or a rate
+type Stage struct {
+	Duration time.Duration `json:"duration"`
+	Workers  int           `json:"workers,omitempty"`
+	Rate     Rate          `json:"rate,omitempty"`
+}
+
+func (s Stage) String() string {
+	if s.Rate > 0 {
+		return s.Duration.String() + ":" + s.Rate.String()
+	}
+	return s.Duration.String() + ":" + strconv.Itoa(s.Workers)
+}
+
+//target returns the number of workers or the rate of s
+func (s Stage) target() float64 {
+	return float64(s.Workers) + float64(s.Rate)
+}
+
+//Stages is a load profile, it can be used as a flag.Value in the form of
+//"30s:10,2m:200,30s:0" for workers or "30s:100/s,2m:1000/s" for rates
+type Stages []Stage
+
+//Set parses the stages from s
+func (ss *Stages) Set(s string) error {
+	var stages Stages
+	for _, field := range strings.Split(s, ",") {
+		i := strings.Index(field, ":")
+		if i < 0 {
+			return fmt.Errorf("invalid stage %q, should be duration:target", field)
+		}
+		var stage Stage
+		var err error
+		if stage.Duration, err = time.ParseDuration(strings.TrimSpace(field[:i])); err != nil {
+			return fmt.Errorf("invalid duration of stage %q", field)
+		}
+		target := strings.TrimSpace(field[i+1:])
+		if strings.Contains(target, "/") {
+			err = stage.Rate.Set(target)
+		} else if stage.Workers, err = strconv.Atoi(target); err != nil {
+			err = fmt.Errorf("invalid workers of stage %q", field)
+		}
+		if err != nil {
+			return err
+		}
+		stages = append(stages, stage)
+	}
+	*ss = stages
+	return nil
+}
+
+func (ss *Stages) String() string {
+	if ss == nil {
+		return ""
+	}
+	fields := make([]string, len(*ss))
+	for i, s := range *ss {
+		fields[i] = s.String()
+	}
+	return strings.Join(fields, ",")
+}
+
+//validate checks the durations and that the stages are all of workers or all of rates
+func (ss Stages) validate() error {
+	for _, s := range ss {
+		if s.Duration <= 0 {
+			return errors.New("duration of stages should be greater than 0")
+		}
+		if s.Workers < 0 || s.Rate < 0 {
+			return errors.New("target of stages should not be negative")
+		}
+		if s.Workers > 0 && ss.rated() {
+			return errors.New("stages should be all of workers or all of rates")
+		}
+	}
+	return nil
+}
+
+//rated reports whether the targets are rates
+func (ss Stages) rated() bool {
+	for _, s := range ss {
+		if s.Rate > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//duration returns the total duration of the stages
+func (ss Stages) duration() time.Duration {
+	d := time.Duration(0)
+	for _, s := range ss {
+		d += s.Duration
+	}
+	return d
+}
+
+//at returns the index of the stage and the target at elapsed, the index is
+//len(ss) once the stages are over
+func (ss Stages) at(elapsed time.Duration) (int, float64) {
+	from := 0.0
+	for i, s := range ss {
+		if elapsed < s.Duration {
+			return i, from + (s.target()-from)*float64(elapsed)/float64(s.Duration)
+		}
+		elapsed -= s.Duration
+		from = s.target()
+	}
+	return len(ss), from
+}
+
+//StageResult is the statistics of a stage, the samples of the warm-up are excluded
+type StageResult struct {
+	Stage     Stage           `json:"stage"`
+	Elapsed   time.Duration   `json:"elapsed"`
+	Requests  int64           `json:"requests"`
+	Errors    int64           `json:"errors"`
+	Histogram *hist.Histogram `json:"histogram"`
+}
+
+//QPS returns the successful requests per second of the stage
+func (s *StageResult) QPS() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Requests-s.Errors) / s.Elapsed.Seconds()
+}
+
+//add adds the samples of a tick
+func (s *StageResult) add(snap *snapshot, interval time.Duration) {
+	s.Elapsed += interval
+	s.Requests += snap.requests
+	s.Errors += snap.errors
+	for _, eplase := range snap.latencies {
+		addLatency(s.Histogram, eplase)
+	}
+}
+
+//stageStep is how often the load follows the ramp of the stages
+const stageStep = 100 * time.Millisecond
+
+//runStages follows the stages until they are over or done is closed, resize sets the
+//number of workers of worker stages, the rate of the pacer is set for rate stages
+func (r *runner) runStages(done <-chan struct{}, resize func(n int)) {
+	ticker := time.NewTicker(stageStep)
+	defer ticker.Stop()
+	stages := r.conf.Stages
+	current := -1
+	for {
+		i, target := stages.at(time.Since(r.start))
+		if i == len(stages) {
+			return
+		}
+		if i != current {
+			log.Printf("stage %d/%d: %v\n", i+1, len(stages), stages[i])
+			//the first stage needs no cut
+			if current >= 0 {
+				select {
+				case r.stageCut <- i:
+				case <-done:
+					return
+				}
+			}
+			current = i
+		}
+		if stages.rated() {
+			r.pacer.set(Rate(target))
+		} else {
+			resize(int(math.Round(target)))
+		}
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+//followRates starts the controller of rate stages, the workers are started as usual
+func (r *runner) followRates(done <-chan struct{}, wg *sync.WaitGroup) {
+	if !r.conf.Stages.rated() {
+		return
+	}
+	wg.Add(1)
+	go func() { r.runStages(done, nil); wg.Done() }()
+}
+
+//benchmarkStaged runs the unary workers following worker stages, every worker
+//uses the clients in turn
+func (r *runner) benchmarkStaged(ctx context.Context, clients []UnaryClient) {
+	var wg sync.WaitGroup
+	done := ctx.Done()
+	if r.pacer != nil {
+		r.pacer.start()
+	}
+	var quits []chan struct{}
+	var recorders []*recorder
+	resize := func(n int) {
+		for len(quits) < n {
+			i := len(quits)
+			if i == len(recorders) {
+				recorders = append(recorders, r.newRecorder())
+			}
+			quit := make(chan struct{})
+			quits = append(quits, quit)
+			wg.Add(1)
+			go func(cli UnaryClient, rec *recorder) { r.runUnary(ctx, quit, cli, rec); wg.Done() }(clients[i%len(clients)], recorders[i])
+		}
+		for len(quits) > n {
+			close(quits[len(quits)-1])
+			quits = quits[:len(quits)-1]
+		}
+	}
+	//the controller holds wg while there may be no worker
+	wg.Add(1)
+	go func() {
+		r.runStages(done, resize)
+		resize(0)
+		wg.Done()
+	}()
+	r.wait(&wg)
+}
@@ -0,0 +1,121 @@
+package fperf
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestStagesSet(t *testing.T) {
+	var ss Stages
+	if err := ss.Set("30s:10,2m:200,30s:0"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ss) != 3 || ss[1] != (Stage{Duration: 2 * time.Minute, Workers: 200}) || ss.rated() {
+		t.Fatalf("unexpected stages %v", ss)
+	}
+	if err := ss.Set("30s:100/s, 1m:5/ms"); err != nil {
+		t.Fatal(err)
+	}
+	if ss[1] != (Stage{Duration: time.Minute, Rate: 5000}) || !ss.rated() {
+		t.Fatalf("unexpected stages %v", ss)
+	}
+	if s := ss.String(); s != "30s:100/s,1m0s:5000/s" {
+		t.Fatalf("unexpected string %q", s)
+	}
+	for _, s := range []string{"30s", "fast:10", "30s:many", "30s:x/s"} {
+		if err := ss.Set(s); err == nil {
+			t.Fatalf("expect error for %q", s)
+		}
+	}
+	ss = Stages{{Duration: time.Second, Workers: 1}, {Duration: time.Second, Rate: 10}}
+	if err := ss.validate(); err == nil {
+		t.Fatal("expect error for mixed stages")
+	}
+}
+
+func TestStagesAt(t *testing.T) {
+	ss := Stages{{Duration: 10 * time.Second, Workers: 10}, {Duration: 20 * time.Second, Workers: 30}, {Duration: 10 * time.Second}}
+	for _, c := range []struct {
+		elapsed time.Duration
+		index   int
+		target  float64
+	}{
+		{0, 0, 0},
+		{5 * time.Second, 0, 5},
+		{10 * time.Second, 1, 10},
+		{20 * time.Second, 1, 20},
+		{35 * time.Second, 2, 15},
+		{time.Minute, 3, 0},
+	} {
+		i, target := ss.at(c.elapsed)
+		if i != c.index || target != c.target {
+			t.Fatalf("expect stage %d target %v at %v, got %d %v", c.index, c.target, c.elapsed, i, target)
+		}
+	}
+}
+
+func TestRunStages(t *testing.T) {
+	Register("test-unary-stages", func(flag *FlagSet) Client {
+		return &slowcli{delay: time.Millisecond}
+	})
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-stages"
+	conf.Connection = 2
+	conf.Tick = time.Second
+	conf.Stages = Stages{{Duration: 200 * time.Millisecond, Workers: 4}, {Duration: 200 * time.Millisecond, Workers: 4}}
+	start := time.Now()
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expect the benchmark stopped after the stages, took %v", elapsed)
+	}
+	if len(res.Stages) != 2 || len(res.Ticks) < 2 {
+		t.Fatalf("expect a tick cut at the end of the stage, got %d stages %d ticks", len(res.Stages), len(res.Ticks))
+	}
+	ramp, plateau := res.Stages[0], res.Stages[1]
+	if ramp.Requests == 0 || plateau.Requests <= ramp.Requests || ramp.Requests+plateau.Requests != res.Requests {
+		t.Fatalf("expect more requests at the plateau, got %d and %d of %d", ramp.Requests, plateau.Requests, res.Requests)
+	}
+	if plateau.Histogram.Count != plateau.Requests {
+		t.Fatalf("expect the samples in the histogram of the stage, got %d", plateau.Histogram.Count)
+	}
+
+	conf.Stages = Stages{{Duration: 200 * time.Millisecond, Rate: 1000}, {Duration: 200 * time.Millisecond, Rate: 1000}}
+	res, err = Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//about 100 requests in the ramp and 200 at the plateau
+	ramp, plateau = res.Stages[0], res.Stages[1]
+	if ramp.Requests < 50 || ramp.Requests > 150 || plateau.Requests < 150 || plateau.Requests > 250 {
+		t.Fatalf("expect the rate to follow the stages, got %d and %d requests", ramp.Requests, plateau.Requests)
+	}
+	if res.Corrected == nil {
+		t.Fatal("expect the corrected histogram with rate stages")
+	}
+}
+
+func TestRunStagesInvalid(t *testing.T) {
+	var sent int64
+	Register("test-stream-stages", func(flag *FlagSet) Client {
+		return &streamcli{requests: &sent}
+	})
+
+	conf := DefaultConfig()
+	conf.Target = "test-stream-stages"
+	conf.Stages = Stages{{Duration: time.Second, Rate: 100}}
+	conf.Rate = 100
+	if _, err := Run(context.Background(), conf); err == nil {
+		t.Fatal("expect error for rate stages with a rate")
+	}
+	conf.Rate = 0
+	conf.Stages = Stages{{Duration: time.Second, Workers: 2}}
+	if _, err := Run(context.Background(), conf); err == nil {
+		t.Fatal("expect error for worker stages with a stream client")
+	}
+}
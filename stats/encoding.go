@@ -0,0 +1,72 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// histogramJSON is the JSON form of a Histogram. Only the buckets with values
+// are encoded, the high dynamic range layout has thousands of empty ones.
+type histogramJSON struct {
+	Options      HistogramOptions  `json:"options"`
+	Count        int64             `json:"count"`
+	Sum          int64             `json:"sum"`
+	SumOfSquares float64           `json:"sum_of_squares"`
+	Min          int64             `json:"min"`
+	Max          int64             `json:"max"`
+	Buckets      []HistogramBucket `json:"buckets"`
+}
+
+// MarshalJSON encodes the histogram with its options, so it can be decoded
+// and merged with histograms created by the same options.
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	v := histogramJSON{
+		Options:      h.opts,
+		Count:        h.Count,
+		Sum:          h.Sum,
+		SumOfSquares: h.SumOfSquares,
+		Min:          h.Min,
+		Max:          h.Max,
+		Buckets:      []HistogramBucket{},
+	}
+	if h.Count == 0 {
+		v.Min, v.Max = 0, 0
+	}
+	for _, b := range h.Buckets {
+		if b.Count > 0 {
+			v.Buckets = append(v.Buckets, b)
+		}
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a histogram encoded by MarshalJSON.
+func (h *Histogram) UnmarshalJSON(data []byte) error {
+	var v histogramJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := v.Options.Validate(); err != nil {
+		return err
+	}
+	*h = *NewHistogram(v.Options)
+	for _, b := range v.Buckets {
+		if h.hdr != nil && b.LowBound >= 0 {
+			h.grow(h.hdr.index(int64(b.LowBound)) + 1)
+		}
+		i := sort.Search(len(h.Buckets), func(i int) bool { return h.Buckets[i].LowBound >= b.LowBound })
+		if i == len(h.Buckets) || h.Buckets[i].LowBound != b.LowBound {
+			return fmt.Errorf("bucket %v does not match the options", b.LowBound)
+		}
+		h.Buckets[i].Count += b.Count
+	}
+	h.Count = v.Count
+	h.Sum = v.Sum
+	h.SumOfSquares = v.SumOfSquares
+	if v.Count > 0 {
+		h.Min = v.Min
+		h.Max = v.Max
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package stats
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHistogramJSON(t *testing.T) {
+	for _, opts := range []HistogramOptions{
+		{SignificantDigits: 3},
+		{NumBuckets: 45, GrowthFactor: 0.3, BaseBucketSize: 1e5},
+	} {
+		h := NewHistogram(opts)
+		for _, v := range []int64{150000, 1200000, 1200000, 30000000, 2000000000} {
+			h.Add(v)
+		}
+		data, err := json.Marshal(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h2 := &Histogram{}
+		if err := json.Unmarshal(data, h2); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(h, h2) {
+			t.Fatalf("expect %v, got %v", h, h2)
+		}
+		//decoded histograms can be merged with the original ones
+		h2.Merge(h)
+		if h2.Count != 2*h.Count {
+			t.Fatalf("expect %d values after merge, got %d", 2*h.Count, h2.Count)
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"options":{"growth_factor":0.3},"buckets":[{"low_bound":7,"count":1}]}`), &Histogram{}); err == nil {
+		t.Fatal("expect error for bucket not matching the options")
+	}
+}
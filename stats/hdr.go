@@ -0,0 +1,66 @@
+package stats
+
+import (
+	"math/bits"
+)
+
+// maxSignificantDigits bounds HistogramOptions.SignificantDigits, more digits
+// make the buckets use too much memory.
+const maxSignificantDigits = 5
+
+// hdrLayout is the bucket layout of a high dynamic range histogram, enabled by
+// HistogramOptions.SignificantDigits. It follows HdrHistogram: values below
+// subBucketCount have buckets of size 1, above that every range [2^k, 2^(k+1))
+// is split into subBucketCount/2 buckets of equal size. The relative error of a
+// value is bounded by 10^-SignificantDigits, and buckets are allocated when a
+// larger value is added, so the range is unbounded and no value is dropped.
+type hdrLayout struct {
+	subBucketCount     int64
+	subBucketHalfCount int64
+	subBucketBits      uint // number of bits of subBucketCount-1
+}
+
+func newHDRLayout(digits int) *hdrLayout {
+	if digits > maxSignificantDigits {
+		digits = maxSignificantDigits
+	}
+	// The smallest power of two which keeps the digits in the lower half of a range.
+	largest := int64(2)
+	for i := 0; i < digits; i++ {
+		largest *= 10
+	}
+	count := int64(1) << uint(bits.Len64(uint64(largest-1)))
+	return &hdrLayout{
+		subBucketCount:     count,
+		subBucketHalfCount: count / 2,
+		subBucketBits:      uint(bits.Len64(uint64(count - 1))),
+	}
+}
+
+// index returns the index of the bucket which contains value, value must not be negative.
+func (l *hdrLayout) index(value int64) int {
+	if value < l.subBucketCount {
+		return int(value)
+	}
+	shift := uint(bits.Len64(uint64(value))) - l.subBucketBits
+	sub := value >> shift
+	return int(l.subBucketCount + int64(shift-1)*l.subBucketHalfCount + sub - l.subBucketHalfCount)
+}
+
+// lowBound returns the lower bound of the bucket i.
+func (l *hdrLayout) lowBound(i int) int64 {
+	if int64(i) < l.subBucketCount {
+		return int64(i)
+	}
+	j := int64(i) - l.subBucketCount
+	shift := uint(j/l.subBucketHalfCount) + 1
+	sub := j%l.subBucketHalfCount + l.subBucketHalfCount
+	return sub << shift
+}
+
+// grow allocates the buckets up to n for the high dynamic range layout.
+func (h *Histogram) grow(n int) {
+	for i := len(h.Buckets); i < n; i++ {
+		h.Buckets = append(h.Buckets, HistogramBucket{LowBound: float64(h.hdr.lowBound(i))})
+	}
+}
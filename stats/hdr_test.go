@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHDRLayout(t *testing.T) {
+	l := newHDRLayout(3)
+	if l.subBucketCount != 2048 {
+		t.Fatalf("expect 2048 sub buckets, got %d", l.subBucketCount)
+	}
+	values := []int64{0, 1, 2047, 2048, 2049, 4095, 4096, 1e6, 1e9, int64(time.Hour), math.MaxInt64 / 2}
+	for i := 0; i < 10000; i++ {
+		values = append(values, rand.Int63n(int64(time.Minute)))
+	}
+	for _, v := range values {
+		i := l.index(v)
+		low, high := l.lowBound(i), l.lowBound(i+1)
+		if v < low || v >= high {
+			t.Fatalf("%d: not in bucket %d [%d, %d)", v, i, low, high)
+		}
+		if v >= l.subBucketCount && float64(high-low)/float64(v) > 0.001 {
+			t.Fatalf("%d: relative error of bucket [%d, %d) exceeds 3 digits", v, low, high)
+		}
+	}
+}
+
+func TestHDRHistogram(t *testing.T) {
+	h := NewHistogram(HistogramOptions{SignificantDigits: 3})
+	for _, v := range []int64{1000, int64(time.Millisecond), int64(10 * time.Minute)} {
+		if err := h.Add(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if h.Count != 3 || h.Max != int64(10*time.Minute) {
+		t.Fatalf("expect no value dropped, got count %d max %d", h.Count, h.Max)
+	}
+	if err := h.Add(-1); err == nil {
+		t.Fatal("expect error for negative value")
+	}
+
+	h.Clear()
+	for i := int64(1); i <= 100000; i++ {
+		h.Add(i * 1000)
+	}
+	for _, q := range []float64{50, 90, 99, 99.9} {
+		expect := q / 100 * 1e8
+		if v := float64(h.Percentile(q)); math.Abs(v-expect)/expect > 0.001 {
+			t.Errorf("p%v: expect %v, got %v", q, expect, v)
+		}
+	}
+
+	//one line per power of two range which has values
+	h.Clear()
+	h.Add(int64(time.Millisecond))
+	h.Add(int64(time.Millisecond) + 1000)
+	h.Add(int64(time.Second))
+	if lines := strings.Count(h.String(), "\n"); lines != 4 {
+		t.Fatalf("expect empty buckets skipped, got %d lines:\n%s", lines, h)
+	}
+}
+
+func TestHDRMerge(t *testing.T) {
+	opts := HistogramOptions{SignificantDigits: 2}
+	h1 := NewHistogram(opts)
+	h2 := NewHistogram(opts)
+	h1.Add(10)
+	h2.Add(int64(time.Second))
+	h1.Merge(h2)
+	if h1.Count != 2 || h1.Max != int64(time.Second) {
+		t.Fatalf("unexpected merge result: count %d max %d", h1.Count, h1.Max)
+	}
+	if len(h1.Buckets) != len(h2.Buckets) {
+		t.Fatalf("expect buckets grown to %d, got %d", len(h2.Buckets), len(h1.Buckets))
+	}
+	if v := h1.Percentile(100); v != int64(time.Second) {
+		t.Fatalf("expect max after merge, got %d", v)
+	}
+}
@@ -11,14 +11,16 @@ import (
 )
 
 // Histogram accumulates values in the form of a histogram with
-// exponentially increased bucket sizes.
+// exponentially increased bucket sizes, or in the high dynamic range layout
+// if HistogramOptions.SignificantDigits is set.
 type Histogram struct {
 	// Count is the total number of values added to the histogram.
 	Count int64
 	// Sum is the sum of all the values added to the histogram.
 	Sum int64
-	// SumOfSquares is the sum of squares of all values.
-	SumOfSquares int64
+	// SumOfSquares is the sum of squares of all values. It is a float64 since
+	// the squares of nanosecond latencies overflow int64 quickly.
+	SumOfSquares float64
 	// Min is the minimum of all the values added to the histogram.
 	Min int64
 	// Max is the maximum of all the values added to the histogram.
@@ -29,36 +31,67 @@ type Histogram struct {
 	opts                          HistogramOptions
 	logBaseBucketSize             float64
 	oneOverLogOnePlusGrowthFactor float64
+	hdr                           *hdrLayout
 }
 
 // HistogramOptions contains the parameters that define the histogram's buckets.
 // The first bucket of the created histogram (with index 0) contains [min, min+n)
 // where n = BaseBucketSize, min = MinValue.
 // Bucket i (i>=1) contains [min + n * m^(i-1), min + n * m^i), where m = 1+GrowthFactor.
+// The values below min are counted in the first bucket and the values above the
+// range in the last one, so Count, Max and the percentiles include every value.
 // The type of the values is int64.
 type HistogramOptions struct {
 	// NumBuckets is the number of buckets.
-	NumBuckets int
+	NumBuckets int `json:"num_buckets,omitempty"`
 	// GrowthFactor is the growth factor of the buckets. A value of 0.1
 	// indicates that bucket N+1 will be 10% larger than bucket N.
-	GrowthFactor float64
+	GrowthFactor float64 `json:"growth_factor,omitempty"`
 	// BaseBucketSize is the size of the first bucket.
-	BaseBucketSize float64
+	BaseBucketSize float64 `json:"base_bucket_size,omitempty"`
 	// MinValue is the lower bound of the first bucket.
-	MinValue int64
+	MinValue int64 `json:"min_value,omitempty"`
+	// SignificantDigits enables the high dynamic range layout if it is greater
+	// than 0, the other options are ignored then. The relative error of a value
+	// is bounded by 10^-SignificantDigits, the range grows automatically with
+	// the values. It is at most 5.
+	SignificantDigits int `json:"significant_digits,omitempty"`
+}
+
+// Validate checks if the options define a usable histogram.
+func (opts HistogramOptions) Validate() error {
+	if opts.SignificantDigits < 0 || opts.SignificantDigits > maxSignificantDigits {
+		return fmt.Errorf("significant digits should be in [0, %d]", maxSignificantDigits)
+	}
+	if opts.SignificantDigits > 0 {
+		return nil
+	}
+	if opts.NumBuckets < 0 {
+		return fmt.Errorf("number of buckets should not be negative")
+	}
+	if opts.GrowthFactor <= 0 {
+		return fmt.Errorf("growth factor should be greater than 0")
+	}
+	if opts.BaseBucketSize < 0 {
+		return fmt.Errorf("base bucket size should not be negative")
+	}
+	return nil
 }
 
 // HistogramBucket represents one histogram bucket.
 type HistogramBucket struct {
 	// LowBound is the lower bound of the bucket.
-	LowBound float64
+	LowBound float64 `json:"low_bound"`
 	// Count is the number of values in the bucket.
-	Count int64
+	Count int64 `json:"count"`
 }
 
 // NewHistogram returns a pointer to a new Histogram object that was created
 // with the provided options.
 func NewHistogram(opts HistogramOptions) *Histogram {
+	if opts.SignificantDigits > 0 {
+		return newHDRHistogram(opts)
+	}
 	if opts.NumBuckets == 0 {
 		opts.NumBuckets = 32
 	}
@@ -84,6 +117,76 @@ func NewHistogram(opts HistogramOptions) *Histogram {
 	return &h
 }
 
+func newHDRHistogram(opts HistogramOptions) *Histogram {
+	if opts.SignificantDigits > maxSignificantDigits {
+		opts.SignificantDigits = maxSignificantDigits
+	}
+	h := &Histogram{
+		Min:  math.MaxInt64,
+		Max:  math.MinInt64,
+		opts: HistogramOptions{SignificantDigits: opts.SignificantDigits},
+		hdr:  newHDRLayout(opts.SignificantDigits),
+	}
+	h.grow(int(h.hdr.subBucketCount))
+	return h
+}
+
+// Mean returns the average of all the values.
+func (h *Histogram) Mean() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return float64(h.Sum) / float64(h.Count)
+}
+
+// StdDev returns the standard deviation of all the values, derived from SumOfSquares.
+func (h *Histogram) StdDev() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	mean := h.Mean()
+	variance := h.SumOfSquares/float64(h.Count) - mean*mean
+	if variance < 0 {
+		// Rounding error of float64.
+		return 0
+	}
+	return math.Sqrt(variance)
+}
+
+// Percentile returns the value below which q percent of the values fall, q is
+// in [0, 100]. The value is interpolated linearly inside the bucket and bounded
+// by Min and Max.
+func (h *Histogram) Percentile(q float64) int64 {
+	if h.Count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.Min
+	}
+	if q >= 100 {
+		return h.Max
+	}
+	rank := q / 100 * float64(h.Count)
+	acc := int64(0)
+	for i, b := range h.Buckets {
+		if b.Count == 0 || float64(acc+b.Count) < rank {
+			acc += b.Count
+			continue
+		}
+		low := math.Max(b.LowBound, float64(h.Min))
+		high := float64(h.Max)
+		if i+1 < len(h.Buckets) {
+			high = math.Min(h.Buckets[i+1].LowBound, high)
+		}
+		if high < low {
+			high = low
+		}
+		v := low + (high-low)*(rank-float64(acc))/float64(b.Count)
+		return int64(math.Round(v))
+	}
+	return h.Max
+}
+
 // Print writes textual output of the histogram values.
 func (h *Histogram) Print(w io.Writer) {
 	avg := float64(h.Sum) / float64(h.Count)
@@ -93,7 +196,11 @@ func (h *Histogram) Print(w io.Writer) {
 		return
 	}
 
-	maxBucketDigitLen := len(strconv.FormatFloat(h.Buckets[len(h.Buckets)-1].LowBound, 'f', 6, 64))
+	buckets, upper := h.DisplayBuckets()
+	maxBucketDigitLen := len(strconv.FormatFloat(buckets[len(buckets)-1].LowBound, 'f', 6, 64))
+	if !math.IsInf(upper, 1) {
+		maxBucketDigitLen = len(strconv.FormatFloat(upper, 'f', 6, 64))
+	}
 	if maxBucketDigitLen < 3 {
 		// For "inf".
 		maxBucketDigitLen = 3
@@ -102,10 +209,15 @@ func (h *Histogram) Print(w io.Writer) {
 	percentMulti := 100 / float64(h.Count)
 
 	accCount := int64(0)
-	for i, b := range h.Buckets {
+	for i, b := range buckets {
+		if h.hdr != nil && b.Count == 0 {
+			continue
+		}
 		fmt.Fprintf(w, "[%*f, ", maxBucketDigitLen, b.LowBound)
-		if i+1 < len(h.Buckets) {
-			fmt.Fprintf(w, "%*f)", maxBucketDigitLen, h.Buckets[i+1].LowBound)
+		if i+1 < len(buckets) {
+			fmt.Fprintf(w, "%*f)", maxBucketDigitLen, buckets[i+1].LowBound)
+		} else if !math.IsInf(upper, 1) {
+			fmt.Fprintf(w, "%*f)", maxBucketDigitLen, upper)
 		} else {
 			fmt.Fprintf(w, "%*s)", maxBucketDigitLen, "inf")
 		}
@@ -119,6 +231,24 @@ func (h *Histogram) Print(w io.Writer) {
 	}
 }
 
+// DisplayBuckets returns the buckets to print or chart and the upper bound of the
+// last one, +Inf if unbounded. The high dynamic range buckets are merged into one
+// bucket per power of two, otherwise there are thousands of them to display.
+func (h *Histogram) DisplayBuckets() ([]HistogramBucket, float64) {
+	if h.hdr == nil {
+		return h.Buckets, math.Inf(1)
+	}
+	half := int(h.hdr.subBucketHalfCount)
+	buckets := []HistogramBucket{{LowBound: 0}}
+	for i, b := range h.Buckets {
+		if i >= int(h.hdr.subBucketCount) && (i-int(h.hdr.subBucketCount))%half == 0 {
+			buckets = append(buckets, HistogramBucket{LowBound: b.LowBound})
+		}
+		buckets[len(buckets)-1].Count += b.Count
+	}
+	return buckets, float64(h.hdr.lowBound(len(h.Buckets)))
+}
+
 // String returns the textual output of the histogram values as string.
 func (h *Histogram) String() string {
 	var b bytes.Buffer
@@ -152,7 +282,7 @@ func (h *Histogram) Add(value int64) error {
 	h.Buckets[bucket].Count++
 	h.Count++
 	h.Sum += value
-	h.SumOfSquares += value * value
+	h.SumOfSquares += float64(value) * float64(value)
 	if value < h.Min {
 		h.Min = value
 	}
@@ -162,7 +292,35 @@ func (h *Histogram) Add(value int64) error {
 	return nil
 }
 
+// AddWithExpectedInterval adds a value to the histogram and back-fills the values
+// a caller sending every interval would have recorded while it was stalled by this
+// one, correcting for coordinated omission like HdrHistogram's RecordValueWithExpectedInterval.
+func (h *Histogram) AddWithExpectedInterval(value, interval int64) error {
+	if err := h.Add(value); err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return nil
+	}
+	for missing := value - interval; missing >= interval; missing -= interval {
+		if err := h.Add(missing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (h *Histogram) findBucket(value int64) (int, error) {
+	if h.hdr != nil {
+		if value < 0 {
+			return 0, fmt.Errorf("negative value: %d", value)
+		}
+		b := h.hdr.index(value)
+		if b >= len(h.Buckets) {
+			h.grow(b + 1)
+		}
+		return b, nil
+	}
 	delta := float64(value - h.opts.MinValue)
 	var b int
 	if delta >= h.opts.BaseBucketSize {
@@ -172,7 +330,8 @@ func (h *Histogram) findBucket(value int64) (int, error) {
 		b = int((math.Log(delta)-h.logBaseBucketSize)*h.oneOverLogOnePlusGrowthFactor + 1)
 	}
 	if b >= len(h.Buckets) {
-		return 0, fmt.Errorf("no bucket for value: %d", value)
+		// the last bucket is unbounded, Percentile interpolates it up to Max
+		b = len(h.Buckets) - 1
 	}
 	return b, nil
 }
@@ -192,6 +351,9 @@ func (h *Histogram) Merge(h2 *Histogram) {
 	if h2.Max > h.Max {
 		h.Max = h2.Max
 	}
+	if h.hdr != nil {
+		h.grow(len(h2.Buckets))
+	}
 	for i, b := range h2.Buckets {
 		h.Buckets[i].Count += b.Count
 	}
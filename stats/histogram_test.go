@@ -0,0 +1,127 @@
+package stats
+
+import (
+	"testing"
+)
+
+func newTestHistogram() *Histogram {
+	return NewHistogram(HistogramOptions{
+		NumBuckets:     16,
+		GrowthFactor:   1.8,
+		BaseBucketSize: 1000,
+		MinValue:       10000,
+	})
+}
+
+func TestAddWithExpectedInterval(t *testing.T) {
+	h := newTestHistogram()
+	if err := h.AddWithExpectedInterval(100000, 20000); err != nil {
+		t.Fatal(err)
+	}
+	//100000 and the back-filled 80000, 60000, 40000, 20000
+	if h.Count != 5 {
+		t.Fatalf("expect 5 values, got %d", h.Count)
+	}
+	if h.Sum != 300000 {
+		t.Fatalf("expect sum 300000, got %d", h.Sum)
+	}
+	if h.Min != 20000 || h.Max != 100000 {
+		t.Fatalf("expect min 20000 max 100000, got %d %d", h.Min, h.Max)
+	}
+
+	h.Clear()
+	if err := h.AddWithExpectedInterval(15000, 20000); err != nil {
+		t.Fatal(err)
+	}
+	if h.Count != 1 {
+		t.Fatalf("expect no back-filled value, got %d values", h.Count)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	h := NewHistogram(HistogramOptions{
+		NumBuckets:     200,
+		GrowthFactor:   0.05,
+		BaseBucketSize: 1,
+		MinValue:       0,
+	})
+	if h.Percentile(99) != 0 {
+		t.Fatal("expect 0 for an empty histogram")
+	}
+	for i := int64(1); i <= 1000; i++ {
+		h.Add(i)
+	}
+	cases := map[float64]int64{
+		0:    1,
+		50:   500,
+		90:   900,
+		99:   990,
+		99.9: 999,
+		100:  1000,
+	}
+	//values are uniform, so the interpolation inside a bucket is close
+	for q, expect := range cases {
+		if v := h.Percentile(q); v < expect-expect/100 || v > expect+expect/100 {
+			t.Errorf("p%v: expect %d, got %d", q, expect, v)
+		}
+	}
+}
+
+func TestPercentileBounded(t *testing.T) {
+	h := newTestHistogram()
+	h.Add(20000)
+	h.Add(21000)
+	if v := h.Percentile(50); v < 20000 || v > 21000 {
+		t.Fatalf("expect p50 between min and max, got %d", v)
+	}
+	if v := h.Percentile(99.9); v > 21000 {
+		t.Fatalf("expect p99.9 bounded by max, got %d", v)
+	}
+}
+
+func TestStdDev(t *testing.T) {
+	h := newTestHistogram()
+	for _, v := range []int64{20000, 40000, 40000, 40000, 50000, 50000, 70000, 90000} {
+		h.Add(v)
+	}
+	if h.Mean() != 50000 {
+		t.Fatalf("expect mean 50000, got %v", h.Mean())
+	}
+	if sd := h.StdDev(); sd < 19999 || sd > 20001 {
+		t.Fatalf("expect stddev 20000, got %v", sd)
+	}
+
+	//squares of second-long latencies overflow int64
+	h.Clear()
+	for i := 0; i < 100; i++ {
+		h.AddWithExpectedInterval(2000000000, 0)
+	}
+	if h.StdDev() != 0 {
+		t.Fatalf("expect stddev 0, got %v", h.StdDev())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []HistogramOptions{
+		{SignificantDigits: 3},
+		{NumBuckets: 16, GrowthFactor: 1.8, BaseBucketSize: 1000, MinValue: 10000},
+		{GrowthFactor: 0.1},
+	}
+	for _, opts := range valid {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("%+v: %v", opts, err)
+		}
+	}
+	invalid := []HistogramOptions{
+		{SignificantDigits: 6},
+		{SignificantDigits: -1},
+		{NumBuckets: 16},
+		{NumBuckets: -1, GrowthFactor: 1},
+		{GrowthFactor: 1, BaseBucketSize: -1},
+	}
+	for _, opts := range invalid {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("%+v: expect error", opts)
+		}
+	}
+}
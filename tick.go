@@ -0,0 +1,96 @@
+package fperf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+//Tick is the statistics of an interval of Config.Tick, latencies are of the successful requests
+type Tick struct {
+	Time      time.Time     `json:"time"`
+	Elapsed   time.Duration `json:"elapsed"`  //since the start of the benchmark
+	Interval  time.Duration `json:"interval"` //the last tick may be shorter than Config.Tick
+	Requests  int64         `json:"requests"`
+	Errors    int64         `json:"errors"`
+	Timeouts  int64         `json:"timeouts"`  //the errors which are timeouts
+	InFlight  int64         `json:"in_flight"` //requests waiting for a response at the end of the tick
+	Sent      int64         `json:"sent"`      //bytes sent, reported by the outcomes of the requests
+	Received  int64         `json:"received"`  //bytes received
+	QPS       float64       `json:"qps"`       //successful requests per second
+	Mean      time.Duration `json:"mean"`
+	P50       time.Duration `json:"p50"`
+	P99       time.Duration `json:"p99"`
+	Max       time.Duration `json:"max"`
+	Corrected time.Duration `json:"corrected,omitempty"` //mean latency corrected for coordinated omission
+	Warmup    bool          `json:"warmup,omitempty"`    //the samples are discarded from the result
+
+	//Operations is the statistics of every operation reported by the outcomes
+	Operations map[string]Tick `json:"operations,omitempty"`
+
+	latencies []time.Duration //sorted samples of the tick, only valid during Exporter.Export
+}
+
+//newTick calculates the statistics of the samples, the latencies of snap are sorted in place
+func newTick(snap *snapshot, now time.Time, interval time.Duration) Tick {
+	t := Tick{
+		Time:     now,
+		Interval: interval,
+		Requests: snap.requests,
+		Errors:   snap.errors,
+		Timeouts: snap.timeouts,
+		InFlight: snap.inflight,
+		Sent:     snap.sent,
+		Received: snap.received,
+	}
+	count := len(snap.latencies)
+	if count == 0 {
+		return t
+	}
+	//the samples of a tick are sorted to get the exact percentiles
+	latencies := snap.latencies
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	sum := time.Duration(0)
+	for _, eplase := range latencies {
+		sum += eplase
+	}
+	t.latencies = latencies
+	t.QPS = float64(count) / interval.Seconds()
+	t.Mean = sum / time.Duration(count)
+	t.P50 = quantile(latencies, 50)
+	t.P99 = quantile(latencies, 99)
+	t.Max = latencies[count-1]
+	return t
+}
+
+func (t Tick) String() string {
+	line := ""
+	if t.Requests > t.Errors {
+		line = fmt.Sprintf("latency %v p50 %v p99 %v max %v ", roundDuration(t.Mean),
+			roundDuration(t.P50), roundDuration(t.P99), roundDuration(t.Max))
+		if t.Corrected > 0 {
+			line += fmt.Sprintf("corrected %v ", roundDuration(t.Corrected))
+		}
+	}
+	line = fmt.Sprintf("%sqps %d errors %d (%s)", line, int64(t.QPS), t.Errors, percent(t.Errors, t.Requests))
+	if t.Timeouts > 0 {
+		line += fmt.Sprintf(" timeouts %d", t.Timeouts)
+	}
+	if t.Sent > 0 || t.Received > 0 {
+		line += fmt.Sprintf(" sent %.2f MB/s received %.2f MB/s", megabytes(t.Sent, t.Interval), megabytes(t.Received, t.Interval))
+	}
+	return line
+}
+
+//quantile returns the q percentile of the sorted latencies
+func quantile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
@@ -0,0 +1,97 @@
+package fperf
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//Warmup is the beginning of a benchmark whose samples are discarded, it lasts a
+//duration or a number of requests, whichever is reached first. It can be used as
+//a flag.Value in the form of "10s" or "1000"
+type Warmup struct {
+	Duration time.Duration `json:"duration,omitempty"`
+	Requests int64         `json:"requests,omitempty"` //number of requests of all the workers
+}
+
+//Set parses s as a number of requests or else a duration
+func (w *Warmup) Set(s string) error {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 {
+		*w = Warmup{Requests: n}
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid warmup %q, should be a duration or a number of requests", s)
+	}
+	*w = Warmup{Duration: d}
+	return nil
+}
+
+func (w *Warmup) String() string {
+	switch {
+	case w.Requests > 0:
+		return strconv.FormatInt(w.Requests, 10)
+	case w.Duration > 0:
+		return w.Duration.String()
+	}
+	return "0"
+}
+
+//warmup tracks the end of the warm-up shared by the workers
+type warmup struct {
+	left    int64 //requests left, accessed atomically
+	active  int32 //accessed atomically
+	counted bool  //whether the warm-up lasts a number of requests
+	ended   time.Time
+	done    chan struct{} //closed after ended is set
+	once    sync.Once
+}
+
+//newWarmup returns nil if conf has no warm-up
+func newWarmup(conf Warmup) *warmup {
+	if conf.Duration <= 0 && conf.Requests <= 0 {
+		return nil
+	}
+	return &warmup{left: conf.Requests, active: 1, counted: conf.Requests > 0, done: make(chan struct{})}
+}
+
+//start ends the warm-up after d if it is set
+func (w *warmup) start(d time.Duration) {
+	if d > 0 {
+		time.AfterFunc(d, w.end)
+	}
+}
+
+//take reports whether a sample belongs to the warm-up, it counts the sample if the
+//warm-up lasts a number of requests. The workers only read the flag once it is over
+func (w *warmup) take() bool {
+	if !w.warming() {
+		return false
+	}
+	if !w.counted {
+		return true
+	}
+	left := atomic.AddInt64(&w.left, -1)
+	if left == 0 {
+		w.end()
+	}
+	return left >= 0
+}
+
+//warming reports whether the warm-up is not over
+func (w *warmup) warming() bool {
+	return atomic.LoadInt32(&w.active) == 1
+}
+
+//end closes done before clearing the flag, so done is closed once a sample is
+//recorded after the warm-up
+func (w *warmup) end() {
+	w.once.Do(func() {
+		w.ended = time.Now()
+		close(w.done)
+		atomic.StoreInt32(&w.active, 0)
+	})
+}
@@ -0,0 +1,75 @@
+package fperf
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestWarmupSet(t *testing.T) {
+	for _, c := range []struct {
+		s      string
+		expect Warmup
+	}{
+		{"1000", Warmup{Requests: 1000}},
+		{"10s", Warmup{Duration: 10 * time.Second}},
+		{"0", Warmup{}},
+	} {
+		var w Warmup
+		if err := w.Set(c.s); err != nil {
+			t.Fatal(err)
+		}
+		if w != c.expect {
+			t.Fatalf("expect %+v for %q, got %+v", c.expect, c.s, w)
+		}
+	}
+	for _, s := range []string{"-1", "-1s", "fast"} {
+		var w Warmup
+		if err := w.Set(s); err == nil {
+			t.Fatalf("expect error for %q", s)
+		}
+	}
+}
+
+func TestRunWarmup(t *testing.T) {
+	Register("test-unary-warmup", func(flag *FlagSet) Client {
+		return &slowcli{delay: 2 * time.Millisecond}
+	})
+
+	conf := DefaultConfig()
+	conf.Target = "test-unary-warmup"
+	conf.N = 100
+	conf.Tick = time.Second
+	conf.Warmup = Warmup{Requests: 30}
+	res, err := Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 70 || res.Histogram.Count != 70 {
+		t.Fatalf("expect 70 requests after the warm-up, got %d, histogram %d", res.Requests, res.Histogram.Count)
+	}
+	if len(res.Ticks) < 2 || !res.Ticks[0].Warmup || res.Ticks[len(res.Ticks)-1].Warmup {
+		t.Fatalf("expect the first tick marked as warm-up, got %+v", res.Ticks)
+	}
+	var warm int64
+	for _, tick := range res.Ticks {
+		if tick.Warmup {
+			warm += tick.Requests
+		}
+	}
+	if warm != 30 {
+		t.Fatalf("expect 30 requests in the ticks of the warm-up, got %d", warm)
+	}
+
+	conf.N = 0
+	conf.Duration = 150 * time.Millisecond
+	conf.Warmup = Warmup{Duration: 50 * time.Millisecond}
+	res, err = Run(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Elapsed > 120*time.Millisecond || res.Requests == 0 || !res.Ticks[0].Warmup {
+		t.Fatalf("expect the measurement after the warm-up, elapsed %v requests %d", res.Elapsed, res.Requests)
+	}
+}